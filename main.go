@@ -15,9 +15,19 @@ func main() {
 			debug.PrintStack()
 		}
 	}()
-	generateAll := flag.Bool("generate-all", false, "Generate .gob archive files from Barchart .csv files for all assets")
+	generateAll := flag.Bool("generate-all", false, "Generate .gob archive files from the configured .csv data sources for all assets")
 	generateSymbol := flag.String("generate", "", "Generate .gob archive for just that symbol")
+	update := flag.Bool("update", false, "Append new bars to existing archives instead of regenerating them, use with -generate-all or -generate")
 	viewArchive := flag.String("archive", "", "Analyze archive contents of the specified symbol")
+	convert := flag.String("convert", "", "Convert .gobz archives of the specified symbol or \"all\" to the memory mapped columnar format")
+	audit := flag.String("audit", "", "Audit raw CSV data and archives of the specified symbol or \"all\" for data quality issues")
+	export := flag.String("export", "", "Export the archives of the specified comma separated symbols to the export directory")
+	exportFormat := flag.String("format", "csv", "Export format, either csv, jsonl or parquet, use with -export")
+	exportFrom := flag.String("from", "", "Only export records from this date onwards (YYYY-MM-DD), use with -export")
+	exportTo := flag.String("to", "", "Only export records before this date (YYYY-MM-DD), use with -export")
+	exportJoin := flag.Bool("join", false, "Join the exported assets on their timestamps into a single table, use with -export")
+	verifyCausality := flag.String("verify-causality", "", "Regenerate features of the specified symbol or \"all\" at sampled timestamps using only past data and report values that differ from the archives")
+	diffArchive := flag.String("diff-archive", "", "Compare the archive at the specified path to the archive at the path passed as the next argument")
 	dataMine := flag.String("data-mine", "", "Data mine strategies using the parameters from the specified YAML file")
 	correlation := flag.String("correlation", "", "Analyze the correlation between IS and OOS metrics of strategies data mined from the specified YAML file")
 	backtest := flag.String("backtest", "", "Backtest strategies defined in the specified YAML file")
@@ -25,11 +35,21 @@ func main() {
 	strategyYaml := flag.String("yaml", "", "Strategy YAML output path, also requires -txt")
 	flag.Parse()
 	if *generateAll {
-		sibylla.Generate(nil)
+		sibylla.Generate(nil, *update)
 	} else if *generateSymbol != "" {
-		sibylla.Generate(generateSymbol)
+		sibylla.Generate(generateSymbol, *update)
 	} else if *viewArchive != "" {
 		sibylla.ViewArchive(*viewArchive)
+	} else if *convert != "" {
+		sibylla.ConvertArchives(*convert)
+	} else if *audit != "" {
+		sibylla.Audit(*audit)
+	} else if *export != "" {
+		sibylla.Export(*export, *exportFormat, *exportFrom, *exportTo, *exportJoin)
+	} else if *verifyCausality != "" {
+		sibylla.VerifyCausality(*verifyCausality)
+	} else if *diffArchive != "" && flag.NArg() == 1 {
+		sibylla.DiffArchives(*diffArchive, flag.Arg(0))
 	} else if *dataMine != "" {
 		sibylla.DataMine(*dataMine)
 	} else if *correlation != "" {
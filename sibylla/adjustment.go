@@ -0,0 +1,140 @@
+package sibylla
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+const adjustmentNone = "none"
+const adjustmentDifference = "difference"
+const adjustmentRatio = "ratio"
+
+type priceAdjustment struct {
+	ratio bool
+	rollDates []time.Time
+	cumulative []float64
+}
+
+type priceSeries struct {
+	symbol GlobexCode
+	dailyMap dailyRecordMap
+	adjustment *priceAdjustment
+	// Prices are adjusted relative to the contract active at this time so that later rolls cannot leak into features
+	anchor time.Time
+}
+
+func (a *Asset) getAdjustment() string {
+	switch a.Adjustment {
+	case "", adjustmentNone:
+		return adjustmentNone
+	case adjustmentDifference, adjustmentRatio:
+		return a.Adjustment
+	default:
+		log.Fatalf("[%s] Invalid adjustment method \"%s\"", a.Symbol, a.Adjustment)
+	}
+	return ""
+}
+
+func newPriceAdjustment(
+	method string,
+	rolls []Roll,
+	dailyCloses dailyCloseMap,
+	asset *Asset,
+) *priceAdjustment {
+	if method == adjustmentNone {
+		return nil
+	}
+	ratio := method == adjustmentRatio
+	rollDates := []time.Time{}
+	for _, roll := range rolls {
+		rollDates = append(rollDates, roll.Date)
+	}
+	cumulative := make([]float64, len(rolls) + 1)
+	if ratio {
+		cumulative[len(rolls)] = 1.0
+	}
+	for i := len(rolls) - 1; i >= 0; i-- {
+		adjustment := getRollAdjustment(ratio, rolls[i], dailyCloses, asset)
+		if ratio {
+			cumulative[i] = cumulative[i + 1] * adjustment
+		} else {
+			cumulative[i] = cumulative[i + 1] + adjustment
+		}
+	}
+	adjustment := priceAdjustment{
+		ratio: ratio,
+		rollDates: rollDates,
+		cumulative: cumulative,
+	}
+	return &adjustment
+}
+
+func getRollAdjustment(
+	ratio bool,
+	roll Roll,
+	dailyCloses dailyCloseMap,
+	asset *Asset,
+) float64 {
+	// The closes of the roll date itself are only known after the session ends, so the gap is taken from the previous trading day
+	previousDate := asset.getCalendar().addTradingDays(roll.Date, -1)
+	oldClose, oldExists := dailyCloses[getGlobexDateKey(roll.From, previousDate)]
+	newClose, newExists := dailyCloses[getGlobexDateKey(roll.To, previousDate)]
+	if oldExists && newExists && oldClose > 0 && newClose > 0 {
+		if ratio {
+			return newClose / oldClose
+		} else {
+			return newClose - oldClose
+		}
+	}
+	fmt.Printf("[%s] Unable to determine roll gap from %s to %s at %s\n", asset.Symbol, roll.From, roll.To, getDateString(roll.Date))
+	if ratio {
+		return 1.0
+	} else {
+		return 0.0
+	}
+}
+
+func (p *priceAdjustment) getIndex(timestamp time.Time) int {
+	date := getDateFromTime(timestamp)
+	return sort.Search(len(p.rollDates), func (i int) bool {
+		return p.rollDates[i].After(date)
+	})
+}
+
+func (p *priceAdjustment) adjust(timestamp time.Time, price float64) float64 {
+	index := p.getIndex(timestamp)
+	if p.ratio {
+		return price * p.cumulative[index]
+	} else {
+		return price + p.cumulative[index]
+	}
+}
+
+// Only the gaps of rolls between timestamp and anchor are applied, which keeps the result independent of later rolls
+func (p *priceAdjustment) adjustRelative(anchor time.Time, timestamp time.Time, price float64) float64 {
+	index := p.getIndex(timestamp)
+	anchorIndex := p.getIndex(anchor)
+	if p.ratio {
+		return price * p.cumulative[index] / p.cumulative[anchorIndex]
+	} else {
+		return price + p.cumulative[index] - p.cumulative[anchorIndex]
+	}
+}
+
+func (p *priceSeries) getSymbol(timestamp time.Time) (GlobexCode, bool) {
+	if p.adjustment == nil {
+		return p.symbol, true
+	}
+	date := getDateFromTime(timestamp)
+	record, exists := p.dailyMap[date]
+	return record.symbol, exists
+}
+
+func (p *priceSeries) adjust(timestamp time.Time, price float64) float64 {
+	if p.adjustment == nil {
+		return price
+	}
+	return p.adjustment.adjustRelative(p.anchor, timestamp, price)
+}
\ No newline at end of file
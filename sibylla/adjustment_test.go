@@ -0,0 +1,152 @@
+package sibylla
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+type adjustmentTestPrice struct {
+	timestamp time.Time
+	price float64
+	expected float64
+}
+
+func getAdjustmentTestDate(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestPriceAdjustment(t *testing.T) {
+	calendars = map[string]*tradingCalendar{
+		"": newDefaultCalendar(),
+	}
+	asset := Asset{
+		Symbol: "ES",
+	}
+	march := GlobexCode{Root: "ES", Month: "H", Year: 2024}
+	june := GlobexCode{Root: "ES", Month: "M", Year: 2024}
+	september := GlobexCode{Root: "ES", Month: "U", Year: 2024}
+	// Monday rolls so that the gap is taken from the closes of the previous Friday
+	firstRoll := getAdjustmentTestDate(2024, time.March, 11)
+	secondRoll := getAdjustmentTestDate(2024, time.June, 10)
+	firstFriday := getAdjustmentTestDate(2024, time.March, 8)
+	secondFriday := getAdjustmentTestDate(2024, time.June, 7)
+	rolls := []Roll{
+		{Date: firstRoll, From: march, To: june},
+		{Date: secondRoll, From: june, To: september},
+	}
+	// The closes of the roll dates are not known during those sessions and must not affect the gaps
+	rollDayCloses := dailyCloseMap{
+		getGlobexDateKey(march, firstRoll): 100,
+		getGlobexDateKey(june, firstRoll): 110,
+		getGlobexDateKey(june, secondRoll): 110,
+		getGlobexDateKey(september, secondRoll): 130,
+	}
+	closes := dailyCloseMap{
+		getGlobexDateKey(march, firstFriday): 100,
+		getGlobexDateKey(june, firstFriday): 102,
+		getGlobexDateKey(june, secondFriday): 110,
+		getGlobexDateKey(september, secondFriday): 115,
+	}
+	for key, close := range rollDayCloses {
+		closes[key] = close
+	}
+	beforeFirst := time.Date(2024, time.March, 8, 15, 0, 0, 0, time.UTC)
+	onFirst := time.Date(2024, time.March, 11, 15, 0, 0, 0, time.UTC)
+	afterSecond := time.Date(2024, time.June, 12, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		method string
+		closes dailyCloseMap
+		prices []adjustmentTestPrice
+	}{
+		{
+			"difference",
+			adjustmentDifference,
+			closes,
+			[]adjustmentTestPrice{
+				{beforeFirst, 95, 95 + 2 + 5},
+				{onFirst, 101, 101 + 5},
+				{afterSecond, 120, 120},
+			},
+		},
+		{
+			"ratio",
+			adjustmentRatio,
+			closes,
+			[]adjustmentTestPrice{
+				{beforeFirst, 95, 95 * 1.02 * 115 / 110},
+				{onFirst, 101, 101 * 115.0 / 110.0},
+				{afterSecond, 120, 120},
+			},
+		},
+		{
+			"roll day closes only",
+			adjustmentDifference,
+			rollDayCloses,
+			[]adjustmentTestPrice{
+				{beforeFirst, 95, 95},
+				{onFirst, 101, 101},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func (t *testing.T) {
+			adjustment := newPriceAdjustment(test.method, rolls, test.closes, &asset)
+			for _, price := range test.prices {
+				adjusted := adjustment.adjust(price.timestamp, price.price)
+				if math.Abs(adjusted - price.expected) > 1e-9 {
+					t.Errorf("%s: adjusted = %f, expected = %f", getTimeString(price.timestamp), adjusted, price.expected)
+				}
+			}
+		})
+	}
+	if newPriceAdjustment(adjustmentNone, rolls, closes, &asset) != nil {
+		t.Errorf("Unadjusted series should not have a price adjustment")
+	}
+}
+
+func TestPriceAdjustmentLaterRoll(t *testing.T) {
+	calendars = map[string]*tradingCalendar{
+		"": newDefaultCalendar(),
+	}
+	asset := Asset{
+		Symbol: "ES",
+	}
+	march := GlobexCode{Root: "ES", Month: "H", Year: 2024}
+	june := GlobexCode{Root: "ES", Month: "M", Year: 2024}
+	september := GlobexCode{Root: "ES", Month: "U", Year: 2024}
+	firstRoll := getAdjustmentTestDate(2024, time.March, 11)
+	secondRoll := getAdjustmentTestDate(2024, time.June, 10)
+	firstFriday := getAdjustmentTestDate(2024, time.March, 8)
+	secondFriday := getAdjustmentTestDate(2024, time.June, 7)
+	closes := dailyCloseMap{
+		getGlobexDateKey(march, firstFriday): 100,
+		getGlobexDateKey(june, firstFriday): 102,
+		getGlobexDateKey(june, secondFriday): 110,
+		getGlobexDateKey(september, secondFriday): 115,
+	}
+	firstRolls := []Roll{
+		{Date: firstRoll, From: march, To: june},
+	}
+	allRolls := append(firstRolls, Roll{Date: secondRoll, From: june, To: september})
+	offset := time.Date(2024, time.March, 7, 15, 0, 0, 0, time.UTC)
+	timestamp := time.Date(2024, time.March, 12, 15, 0, 0, 0, time.UTC)
+	for _, method := range []string{adjustmentDifference, adjustmentRatio} {
+		getFeature := func (rolls []Roll) float64 {
+			series := priceSeries{
+				adjustment: newPriceAdjustment(method, rolls, closes, &asset),
+				anchor: timestamp,
+			}
+			close := series.adjust(timestamp, 104)
+			offsetClose := series.adjust(offset, 97)
+			momentum, _ := getRateOfChange(close, offsetClose)
+			return momentum
+		}
+		before := getFeature(firstRolls)
+		after := getFeature(allRolls)
+		if math.Abs(before - after) > 1e-12 {
+			t.Errorf("%s: momentum changed from %f to %f after adding a later roll", method, before, after)
+		}
+	}
+}
\ No newline at end of file
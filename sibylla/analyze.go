@@ -16,6 +16,13 @@ type ArchiveModel struct {
 	Symbol string `json:"symbol"`
 	Plot string `json:"plot"`
 	Properties []PropertyStats `json:"properties"`
+	Rolls []RollModel `json:"rolls"`
+}
+
+type RollModel struct {
+	Date string `json:"date"`
+	From string `json:"from"`
+	To string `json:"to"`
 }
 
 type PropertyStats struct {
@@ -36,8 +43,18 @@ func ViewArchive(symbol string) {
 	} else {
 		fileName = fmt.Sprintf("%s.F1.%s", symbol, archiveExtension)
 	}
-	archivePath := filepath.Join(configuration.GobPath, fileName)
+	archivePath := getLoadPath(filepath.Join(configuration.GobPath, fileName))
+	header := readArchiveHeader(archivePath)
 	archive := readArchive(archivePath)
+	archive.alignColumns()
+	var mismatches []string
+	asset, exists := find(*assets, func (a Asset) bool {
+		return a.Symbol == archive.Symbol
+	})
+	if exists && header.Version == archiveVersion {
+		mismatches = header.getMismatches(&asset)
+	}
+	header.print(archivePath, mismatches)
 	clearDirectory(configuration.TempPath)
 	dailyRecordsPlotPath := filepath.Join(configuration.TempPath, dailyRecordsPlot)
 	plotDailyRecords(archive.DailyRecords, dailyRecordsPlotPath)
@@ -46,6 +63,7 @@ func ViewArchive(symbol string) {
 		Symbol: symbol,
 		Plot: getFileURL(dailyRecordsPlotPath),
 		Properties: propertyStats,
+		Rolls: getRollModels(archive),
 	}
 	title := fmt.Sprintf("View Archive - %s", symbol)
 	runBrowser(title, archiveScript, model, false)
@@ -107,4 +125,17 @@ func getPropertyStatsWorker(feature archiveProperty, archive Archive) PropertySt
 		Mean: mean,
 		StdDev: stdDev,
 	}
+}
+
+func getRollModels(archive Archive) []RollModel {
+	rolls := []RollModel{}
+	for _, roll := range archive.Rolls {
+		model := RollModel{
+			Date: getDateString(roll.Date),
+			From: roll.From.String(),
+			To: roll.To.String(),
+		}
+		rolls = append(rolls, model)
+	}
+	return rolls
 }
\ No newline at end of file
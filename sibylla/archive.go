@@ -3,8 +3,11 @@ package sibylla
 import (
 	"compress/gzip"
 	"encoding/gob"
+	"fmt"
 	"log"
+	"math"
 	"os"
+	"slices"
 	"time"
 )
 
@@ -12,8 +15,13 @@ const archiveExtension = "gobz"
 
 type Archive struct {
 	Symbol string
+	Timezone string
+	Features []string
+	HoldingTimes []int
 	DailyRecords []DailyRecord
 	IntradayRecords []FeatureRecord
+	Rolls []Roll
+	Bars []BarSeries
 }
 
 type DailyRecord struct {
@@ -23,24 +31,16 @@ type DailyRecord struct {
 
 type FeatureRecord struct {
 	Timestamp time.Time
-	Momentum1D *float64
-	Momentum1DLag *float64
-	Momentum2D *float64
-	Momentum5D *float64
-	Momentum10D *float64
-	Momentum1H *float64
-	Momentum2H *float64
-	Momentum4H *float64
-	Momentum8H *float64
-	Returns4H *ReturnsRecord
-	Returns8H *ReturnsRecord
-	Returns16H *ReturnsRecord
-	Returns24H *ReturnsRecord
-	Returns48H *ReturnsRecord
-	Returns72H *ReturnsRecord
+	// Wall clock time in the reference timezone of the backtest, set when loading archives
+	localTime time.Time
+	// Feature values in the order of Archive.Features, NaN if missing
+	Features []float64
+	// Returns in the order of Archive.HoldingTimes
+	Returns []ReturnsRecord
 }
 
 type ReturnsRecord struct {
+	Valid bool
 	High int
 	Low int
 	Close1 int
@@ -49,11 +49,17 @@ type ReturnsRecord struct {
 
 type featureAccessor struct {
 	name string
-	anchored bool
+	normalization string
+	discrete bool
 	get func (*FeatureRecord) *float64
 	set func (*FeatureRecord, float64)
 }
 
+// Only ranks are confined to [0, 1], z-scores and raw values such as discrete features are unbounded
+func (a *featureAccessor) isBounded() bool {
+	return a.normalization == normalizationRank || a.normalization == normalizationAnchoredRank
+}
+
 type returnsAccessor struct {
 	name string
 	holdingTime int
@@ -66,6 +72,54 @@ type archiveProperty struct {
 }
 
 func readArchive(path string) Archive {
+	if isColumnarPath(path) {
+		return readColumnarArchive(path)
+	}
+	header := readArchiveHeader(path)
+	if header.Version == 1 {
+		return readLegacyArchive(path)
+	}
+	if header.Version != archiveVersion {
+		log.Fatalf("Archive %s has format version %d but version %d is required, regenerate it", path, header.Version, archiveVersion)
+	}
+	var archive Archive
+	decodeArchive(path, func (decoder *gob.Decoder) error {
+		err := decoder.Decode(&header)
+		if err != nil {
+			return err
+		}
+		return decoder.Decode(&archive)
+	})
+	return archive
+}
+
+// Columnar archives only load the intraday records within the date range, .gobz archives are loaded completely
+func readArchiveRange(path string, dateMin SerializableDate, dateMax SerializableDate) Archive {
+	if isColumnarPath(path) {
+		return readColumnarArchiveRange(path, dateMin, dateMax)
+	}
+	return readArchive(path)
+}
+
+func readArchiveHeader(path string) ArchiveHeader {
+	if isColumnarPath(path) {
+		return readColumnarHeader(path)
+	}
+	var header ArchiveHeader
+	decodeArchive(path, func (decoder *gob.Decoder) error {
+		err := decoder.Decode(&header)
+		if err != nil || header.Version == 0 {
+			// Legacy archives start with the archive itself
+			header = ArchiveHeader{
+				Version: 1,
+			}
+		}
+		return nil
+	})
+	return header
+}
+
+func decodeArchive(path string, decode func (*gob.Decoder) error) {
 	file, err := os.Open(path)
 	if err != nil {
 		log.Fatalf("Failed to read archive %s: %v", path, err)
@@ -77,15 +131,13 @@ func readArchive(path string) Archive {
 	}
 	defer reader.Close()
 	decoder := gob.NewDecoder(reader)
-	var archive Archive
-	err = decoder.Decode(&archive)
+	err = decode(decoder)
 	if err != nil {
 		log.Fatalf("Failed to read decompressed Gob data from %s: %v", path, err)
 	}
-	return archive
 }
 
-func writeArchive(path string, archive *Archive) int64 {
+func writeArchive(path string, header *ArchiveHeader, archive *Archive) int64 {
 	{
 		file, err := os.Create(path)
 		if err != nil {
@@ -95,6 +147,10 @@ func writeArchive(path string, archive *Archive) int64 {
 		writer := gzip.NewWriter(file)
 		defer writer.Close()
 		encoder := gob.NewEncoder(writer)
+		err = encoder.Encode(header)
+		if err != nil {
+			log.Fatalf("Failed to encode archive header %s: %v", path, err)
+		}
 		err = encoder.Encode(archive)
 		if err != nil {
 			log.Fatalf("Failed to encode archive %s: %v", path, err)
@@ -109,140 +165,70 @@ func writeArchive(path string, archive *Archive) int64 {
 }
 
 func getFeatureAccessors() []featureAccessor {
-	accessors := []featureAccessor{
-		{
-			name: "momentum1D",
-			get: func (f *FeatureRecord) *float64 {
-				return f.Momentum1D
-			},
-			set: func (f *FeatureRecord, x float64) {
-				f.Momentum1D = &x
-			},
-		},
-		{
-			name: "momentum1DLag",
-			get: func (f *FeatureRecord) *float64 {
-				return f.Momentum1DLag
-			},
-			set: func (f *FeatureRecord, x float64) {
-				f.Momentum1DLag = &x
-			},
-		},
-		{
-			name: "momentum2D",
-			get: func (f *FeatureRecord) *float64 {
-				return f.Momentum2D
-			},
-			set: func (f *FeatureRecord, x float64) {
-				f.Momentum2D = &x
-			},
-		},
-		{
-			name: "momentum5D",
-			get: func (f *FeatureRecord) *float64 {
-				return f.Momentum5D
-			},
-			set: func (f *FeatureRecord, x float64) {
-				f.Momentum5D = &x
-			},
-		},
-		{
-			name: "momentum10D",
-			get: func (f *FeatureRecord) *float64 {
-				return f.Momentum10D
-			},
-			set: func (f *FeatureRecord, x float64) {
-				f.Momentum10D = &x
-			},
-		},
-		{
-			name: "momentum1H",
+	accessors := []featureAccessor{}
+	for i, column := range getFeatureColumns() {
+		accessor := featureAccessor{
+			name: column.name,
+			normalization: column.normalization,
+			discrete: column.discrete,
 			get: func (f *FeatureRecord) *float64 {
-				return f.Momentum1H
-			},
-			set: func (f *FeatureRecord, x float64) {
-				f.Momentum1H = &x
-			},
-		},
-		{
-			name: "momentum2H",
-			get: func (f *FeatureRecord) *float64 {
-				return f.Momentum2H
-			},
-			set: func (f *FeatureRecord, x float64) {
-				f.Momentum2H = &x
-			},
-		},
-		{
-			name: "momentum4H",
-			get: func (f *FeatureRecord) *float64 {
-				return f.Momentum4H
-			},
-			set: func (f *FeatureRecord, x float64) {
-				f.Momentum4H = &x
-			},
-		},
-		{
-			name: "momentum8H",
-			get: func (f *FeatureRecord) *float64 {
-				return f.Momentum8H
+				if math.IsNaN(f.Features[i]) {
+					return nil
+				}
+				return &f.Features[i]
 			},
 			set: func (f *FeatureRecord, x float64) {
-				f.Momentum8H = &x
+				f.Features[i] = x
 			},
-		},
+		}
+		accessors = append(accessors, accessor)
 	}
 	return accessors
 }
 
+// Data mining ranges are quantiles so z-scores and raw values are only available to manually defined conditions.
+// Discrete features are the exception, their ranges are scaled to the values observed.
+func getMiningFeatureAccessors() []featureAccessor {
+	accessors := getFeatureAccessors()
+	if !configuration.QuantileTransform {
+		return accessors
+	}
+	return slices.DeleteFunc(accessors, func (accessor featureAccessor) bool {
+		crossSectionalRank := slices.ContainsFunc(*featureCatalog, func (d FeatureDefinition) bool {
+			return d.Name == accessor.name && d.Type == featureCrossSectionalRank
+		})
+		return accessor.normalization != normalizationRank && accessor.normalization != normalizationAnchoredRank && !crossSectionalRank && !accessor.discrete
+	})
+}
+
 func getReturnsAccessors() []returnsAccessor {
-	accessors := []returnsAccessor{
-		{
-			name: "returns4H",
-			holdingTime: 4,
+	accessors := []returnsAccessor{}
+	for i, holdingTime := range getHoldingTimes() {
+		accessor := returnsAccessor{
+			name: getReturnsName(holdingTime),
+			holdingTime: holdingTime,
 			get: func (f *FeatureRecord) *ReturnsRecord {
-				return f.Returns4H
-			},
-		},
-		{
-			name: "returns8H",
-			holdingTime: 8,
-			get: func (f *FeatureRecord) *ReturnsRecord {
-				return f.Returns8H
-			},
-		},
-		{
-			name: "returns16H",
-			holdingTime: 16,
-			get: func (f *FeatureRecord) *ReturnsRecord {
-				return f.Returns16H
-			},
-		},
-		{
-			name: "returns24H",
-			holdingTime: 24,
-			get: func (f *FeatureRecord) *ReturnsRecord {
-				return f.Returns24H
-			},
-		},
-		{
-			name: "returns48H",
-			holdingTime: 48,
-			get: func (f *FeatureRecord) *ReturnsRecord {
-				return f.Returns48H
-			},
-		},
-		{
-			name: "returns72H",
-			holdingTime: 72,
-			get: func (f *FeatureRecord) *ReturnsRecord {
-				return f.Returns72H
+				if !f.Returns[i].Valid {
+					return nil
+				}
+				return &f.Returns[i]
 			},
-		},
+		}
+		accessors = append(accessors, accessor)
 	}
 	return accessors
 }
 
+func getReturnsAccessor(holdingTime int) (returnsAccessor, bool) {
+	return find(getReturnsAccessors(), func (r returnsAccessor) bool {
+		return r.holdingTime == holdingTime
+	})
+}
+
+func getReturnsName(holdingTime int) string {
+	return fmt.Sprintf("returns%dH", holdingTime)
+}
+
 func getArchiveProperties() []archiveProperty {
 	properties := []archiveProperty{}
 	featureAccessors := getFeatureAccessors()
@@ -274,10 +260,19 @@ func getArchiveProperties() []archiveProperty {
 }
 
 func (f *FeatureRecord) hasReturns() bool {
-	return f.Returns4H != nil ||
-		f.Returns8H != nil ||
-		f.Returns16H != nil ||
-		f.Returns24H != nil ||
-		f.Returns48H != nil ||
-		f.Returns72H != nil
+	for _, returns := range f.Returns {
+		if returns.Valid {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnchoredFeatures() bool {
+	for _, accessor := range getFeatureAccessors() {
+		if accessor.normalization == normalizationAnchoredRank {
+			return true
+		}
+	}
+	return false
 }
\ No newline at end of file
@@ -13,6 +13,10 @@ type Asset struct {
 	Symbol string `yaml:"symbol"`
 	BarchartSymbol string `yaml:"barchartSymbol"`
 	Name string `yaml:"name"`
+	Timezone string `yaml:"timezone"`
+	Calendar string `yaml:"calendar"`
+	Groups []string `yaml:"groups"`
+	Source *SourceConfiguration `yaml:"source"`
 
 	// Contract filtering fields
 	LegacyCutoff *GlobexCode `yaml:"legacyCutoff"`
@@ -24,6 +28,10 @@ type Asset struct {
 	CutoffDate *ConfigDate `yaml:"cutoffDate"`
 	FRecords *int `yaml:"fRecords"`
 	FeaturesOnly bool `yaml:"featuresOnly"`
+	Adjustment string `yaml:"adjustment"`
+	Roll *RollConfiguration `yaml:"roll"`
+	LastTrade *ExpiryRule `yaml:"lastTrade"`
+	FirstNotice *ExpiryRule `yaml:"firstNotice"`
 
 	// Asset definition
 	Currency string `yaml:"currency"`
@@ -35,6 +43,10 @@ type Asset struct {
 	Spread int `yaml:"spread"`
 
 	ShortBias bool `yaml:"shortBias"`
+
+	// Synthetic assets
+	Synthetic string `yaml:"synthetic"`
+	Legs []SyntheticLeg `yaml:"legs"`
 }
 
 type ConfigDate struct {
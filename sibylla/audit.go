@@ -0,0 +1,397 @@
+package sibylla
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+const auditDefaultPath = "audit.json"
+const auditDefaultSigma = 10.0
+const auditHourPresenceRatio = 0.5
+const auditGapDays = 2
+const auditStaleBars = 6
+const auditOpenInterestFactor = 10.0
+const auditSpikeReversion = 0.5
+const auditSummaryLimit = 10
+
+type AuditReport struct {
+	Symbol string `json:"symbol"`
+	IncludedRecords int `json:"includedRecords"`
+	ExcludedRecords int `json:"excludedRecords"`
+	MissingHours []AuditIssue `json:"missingHours"`
+	Gaps []AuditIssue `json:"gaps"`
+	StaleCloses []AuditIssue `json:"staleCloses"`
+	Spikes []AuditIssue `json:"spikes"`
+	InvalidPrices []AuditIssue `json:"invalidPrices"`
+	OpenInterest []AuditIssue `json:"openInterest"`
+	Archive []AuditIssue `json:"archive"`
+	ExcludeRecords []string `json:"excludeRecords"`
+}
+
+type AuditIssue struct {
+	Contract string `json:"contract"`
+	Time string `json:"time"`
+	End *string `json:"end"`
+	Value float64 `json:"value"`
+	Description string `json:"description"`
+}
+
+type auditBar struct {
+	symbol GlobexCode
+	timestamp time.Time
+	record intradayRecord
+}
+
+func Audit(symbol string) {
+	loadConfiguration()
+	start := time.Now()
+	auditAssets := []Asset{}
+	for _, asset := range *assets {
+		if symbol == "all" || asset.Symbol == symbol {
+			auditAssets = append(auditAssets, asset)
+		}
+	}
+	if len(auditAssets) == 0 {
+		log.Fatalf("Unable to find an asset matching symbol %s", symbol)
+	}
+	reports := parallelMap(auditAssets, auditAsset)
+	path := auditDefaultPath
+	if configuration.AuditPath != "" {
+		path = configuration.AuditPath
+	}
+	jsonBytes, err := json.MarshalIndent(reports, "", "\t")
+	if err != nil {
+		log.Fatal("Failed to serialize audit report to JSON:", err)
+	}
+	writeFile(path, string(jsonBytes))
+	delta := time.Since(start)
+	fmt.Printf("Audited %d assets in %.2f s\n\n", len(reports), delta.Seconds())
+	printAuditSummary(reports)
+	fmt.Printf("\nWrote audit report to %s\n", path)
+}
+
+func auditAsset(asset Asset) AuditReport {
+	source := asset.getDataSource()
+	dailyRecordsResult := readDailyRecords(asset, source, time.Time{})
+	intradayRecords := readIntradayRecords(asset, source, time.Time{})
+	report := AuditReport{
+		Symbol: asset.Symbol,
+		IncludedRecords: dailyRecordsResult.includedRecords,
+		ExcludedRecords: dailyRecordsResult.excludedRecords,
+		MissingHours: []AuditIssue{},
+		Gaps: []AuditIssue{},
+		StaleCloses: []AuditIssue{},
+		Spikes: []AuditIssue{},
+		InvalidPrices: []AuditIssue{},
+		OpenInterest: []AuditIssue{},
+		Archive: []AuditIssue{},
+		ExcludeRecords: []string{},
+	}
+	openIntRecords := dailyRecordsResult.openIntRecords
+	auditDailyRecords(openIntRecords, asset.getCalendar(), &report)
+	bars := auditMissingHours(openIntRecords, intradayRecords, &report)
+	auditIntradayBars(bars, &asset, &report)
+	auditArchive(&asset, &report)
+	return report
+}
+
+func auditDailyRecords(openIntRecords []openInterestRecords, calendar *tradingCalendar, report *AuditReport) {
+	openInterest := map[GlobexCode]int{}
+	var previousDate *time.Time
+	for _, datedRecords := range openIntRecords {
+		date := datedRecords.date
+		dateString := getDateString(date)
+		front := datedRecords.records[0]
+		if previousDate != nil {
+			missingDays := 0
+			for d := previousDate.AddDate(0, 0, 1); d.Before(date); d = d.AddDate(0, 0, 1) {
+				if calendar.isTradingDay(d) {
+					missingDays++
+				}
+			}
+			if missingDays >= auditGapDays {
+				end := dateString
+				issue := AuditIssue{
+					Contract: front.symbol.String(),
+					Time: getDateString(*previousDate),
+					End: &end,
+					Value: float64(missingDays),
+					Description: fmt.Sprintf("%d trading days without daily records", missingDays),
+				}
+				report.Gaps = append(report.Gaps, issue)
+			}
+		}
+		if front.openInterest <= 0 {
+			issue := AuditIssue{
+				Contract: front.symbol.String(),
+				Time: dateString,
+				Value: float64(front.openInterest),
+				Description: "Front contract has no open interest",
+			}
+			report.OpenInterest = append(report.OpenInterest, issue)
+		}
+		for _, record := range datedRecords.records {
+			if record.close <= 0 {
+				issue := AuditIssue{
+					Contract: record.symbol.String(),
+					Time: dateString,
+					Value: record.close,
+					Description: "Daily close is not positive",
+				}
+				report.InvalidPrices = append(report.InvalidPrices, issue)
+			}
+			previousOpenInterest, exists := openInterest[record.symbol]
+			if exists && previousOpenInterest > 0 && record.openInterest > 0 {
+				factor := float64(record.openInterest) / float64(previousOpenInterest)
+				if factor > auditOpenInterestFactor || factor < 1.0 / auditOpenInterestFactor {
+					issue := AuditIssue{
+						Contract: record.symbol.String(),
+						Time: dateString,
+						Value: factor,
+						Description: fmt.Sprintf("Open interest changed from %d to %d", previousOpenInterest, record.openInterest),
+					}
+					report.OpenInterest = append(report.OpenInterest, issue)
+				}
+			}
+			openInterest[record.symbol] = record.openInterest
+		}
+		previousDate = &date
+	}
+}
+
+func auditMissingHours(
+	openIntRecords []openInterestRecords,
+	intradayRecords intradayRecordsMap,
+	report *AuditReport,
+) []auditBar {
+	hourCounts := make([]int, hoursPerDay)
+	bars := []auditBar{}
+	presentHours := [][]bool{}
+	for _, datedRecords := range openIntRecords {
+		front := datedRecords.records[0]
+		present := make([]bool, hoursPerDay)
+		for hour := range hoursPerDay {
+			timestamp := datedRecords.date.Add(time.Duration(hour) * time.Hour)
+			key := getGlobexTimeKey(front.symbol, timestamp)
+			record, exists := intradayRecords[key]
+			if !exists {
+				continue
+			}
+			present[hour] = true
+			hourCounts[hour]++
+			bar := auditBar{
+				symbol: front.symbol,
+				timestamp: timestamp,
+				record: record,
+			}
+			bars = append(bars, bar)
+		}
+		presentHours = append(presentHours, present)
+	}
+	expectedHours := []int{}
+	for hour, count := range hourCounts {
+		if float64(count) >= auditHourPresenceRatio * float64(len(openIntRecords)) {
+			expectedHours = append(expectedHours, hour)
+		}
+	}
+	for i, datedRecords := range openIntRecords {
+		missingHours := []string{}
+		for _, hour := range expectedHours {
+			if !presentHours[i][hour] {
+				missingHours = append(missingHours, getTimeOfDayString(time.Duration(hour) * time.Hour))
+			}
+		}
+		if len(missingHours) > 0 {
+			issue := AuditIssue{
+				Contract: datedRecords.records[0].symbol.String(),
+				Time: getDateString(datedRecords.date),
+				Value: float64(len(missingHours)),
+				Description: fmt.Sprintf("Missing %d of %d hours: %s", len(missingHours), len(expectedHours), strings.Join(missingHours, ", ")),
+			}
+			report.MissingHours = append(report.MissingHours, issue)
+		}
+	}
+	return bars
+}
+
+func auditIntradayBars(bars []auditBar, asset *Asset, report *AuditReport) {
+	sigma := auditDefaultSigma
+	if configuration.AuditSigma > 0 {
+		sigma = configuration.AuditSigma
+	}
+	returns := []float64{}
+	returnIndexes := []int{}
+	staleStart := 0
+	for i, bar := range bars {
+		record := bar.record
+		timeString := getTimeString(bar.timestamp)
+		if record.high <= 0 || record.low <= 0 || record.close <= 0 || record.low > record.high || record.close < record.low || record.close > record.high {
+			issue := AuditIssue{
+				Contract: bar.symbol.String(),
+				Time: timeString,
+				Value: record.close,
+				Description: fmt.Sprintf("Invalid bar: high = %g, low = %g, close = %g", record.high, record.low, record.close),
+			}
+			report.InvalidPrices = append(report.InvalidPrices, issue)
+			report.ExcludeRecords = append(report.ExcludeRecords, timeString)
+		}
+		if i == 0 || bars[i - 1].symbol != bar.symbol || bars[i - 1].record.close != record.close {
+			auditStaleCloses(bars, staleStart, i, report)
+			staleStart = i
+		}
+		if i > 0 && bars[i - 1].symbol == bar.symbol && bars[i - 1].record.close > 0 && record.close > 0 {
+			r := math.Log(record.close / bars[i - 1].record.close)
+			returns = append(returns, r)
+			returnIndexes = append(returnIndexes, i)
+		}
+	}
+	auditStaleCloses(bars, staleStart, len(bars), report)
+	if len(returns) < 2 {
+		return
+	}
+	mean, stdDev := stat.MeanStdDev(returns, nil)
+	if !(stdDev > 0) {
+		// Flat series have no spikes
+		returns = nil
+	}
+	for j, r := range returns {
+		deviation := (r - mean) / stdDev
+		if math.Abs(deviation) <= sigma {
+			continue
+		}
+		bar := bars[returnIndexes[j]]
+		timeString := getTimeString(bar.timestamp)
+		previousClose := bars[returnIndexes[j] - 1].record.close
+		ticks := int((bar.record.close - previousClose) / asset.TickSize)
+		issue := AuditIssue{
+			Contract: bar.symbol.String(),
+			Time: timeString,
+			Value: deviation,
+			Description: fmt.Sprintf("Close moved from %g to %g (%d ticks)", previousClose, bar.record.close, ticks),
+		}
+		report.Spikes = append(report.Spikes, issue)
+		reverted := j + 1 < len(returns) &&
+			returnIndexes[j + 1] == returnIndexes[j] + 1 &&
+			math.Signbit(returns[j + 1] - mean) != math.Signbit(r - mean) &&
+			math.Abs((returns[j + 1] - mean) / stdDev) > auditSpikeReversion * sigma
+		if reverted || ticks < -returnsLimit || ticks > returnsLimit {
+			report.ExcludeRecords = append(report.ExcludeRecords, timeString)
+		}
+	}
+	slices.Sort(report.ExcludeRecords)
+	report.ExcludeRecords = slices.Compact(report.ExcludeRecords)
+}
+
+func auditStaleCloses(bars []auditBar, start, end int, report *AuditReport) {
+	length := end - start
+	if length < auditStaleBars {
+		return
+	}
+	first := bars[start]
+	endString := getTimeString(bars[end - 1].timestamp)
+	issue := AuditIssue{
+		Contract: first.symbol.String(),
+		Time: getTimeString(first.timestamp),
+		End: &endString,
+		Value: float64(length),
+		Description: fmt.Sprintf("Close remained at %g for %d bars", first.record.close, length),
+	}
+	report.StaleCloses = append(report.StaleCloses, issue)
+}
+
+func auditArchive(asset *Asset, report *AuditReport) {
+	path := getArchivePath(asset.Symbol, 1)
+	_, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		issue := AuditIssue{
+			Description: fmt.Sprintf("Archive does not exist: %s", path),
+		}
+		report.Archive = append(report.Archive, issue)
+		return
+	}
+	// Legacy archives are migrated, other versions would abort the audit of all remaining assets
+	header := readArchiveHeader(path)
+	if header.Version != 1 && header.Version != archiveVersion {
+		issue := AuditIssue{
+			Value: float64(header.Version),
+			Description: fmt.Sprintf("Archive %s has format version %d but version %d is required, skipping it", path, header.Version, archiveVersion),
+		}
+		report.Archive = append(report.Archive, issue)
+		return
+	}
+	archive := readArchive(path)
+	archive.alignColumns()
+	accessors := getFeatureAccessors()
+	addIssue := func (timestamp time.Time, value float64, description string) {
+		issue := AuditIssue{
+			Time: getTimeString(timestamp),
+			Value: value,
+			Description: description,
+		}
+		report.Archive = append(report.Archive, issue)
+	}
+	for i := range archive.IntradayRecords {
+		record := &archive.IntradayRecords[i]
+		if i > 0 && !archive.IntradayRecords[i - 1].Timestamp.Before(record.Timestamp) {
+			addIssue(record.Timestamp, 0, "Timestamps are not strictly increasing")
+		}
+		for _, accessor := range accessors {
+			pointer := accessor.get(record)
+			if pointer != nil && (math.IsNaN(*pointer) || math.IsInf(*pointer, 0)) {
+				addIssue(record.Timestamp, 0, fmt.Sprintf("Invalid value in feature %s", accessor.name))
+			}
+		}
+		for _, returns := range getReturnsAccessors() {
+			returnsRecord := returns.get(record)
+			if returnsRecord == nil {
+				continue
+			}
+			if returnsRecord.Low > returnsRecord.High || returnsRecord.Close2 < returnsRecord.Low || returnsRecord.Close2 > returnsRecord.High {
+				description := fmt.Sprintf("Inconsistent ticks in %s: high = %d, low = %d, close = %d", returns.name, returnsRecord.High, returnsRecord.Low, returnsRecord.Close2)
+				addIssue(record.Timestamp, float64(returnsRecord.Close2 - returnsRecord.Close1), description)
+			}
+		}
+	}
+}
+
+func printAuditSummary(reports []AuditReport) {
+	format := "%-8s %9s %10s %8s %8s %8s %8s %8s %8s %10s\n"
+	fmt.Printf(format, "Symbol", "Excluded", "Missing", "Gaps", "Stale", "Spikes", "Invalid", "OI", "Archive", "Exclusions")
+	for _, report := range reports {
+		excluded := getPercentageFromInts(report.ExcludedRecords, report.IncludedRecords + report.ExcludedRecords)
+		fmt.Printf(
+			format,
+			report.Symbol,
+			fmt.Sprintf("%.2f%%", excluded),
+			fmt.Sprint(len(report.MissingHours)),
+			fmt.Sprint(len(report.Gaps)),
+			fmt.Sprint(len(report.StaleCloses)),
+			fmt.Sprint(len(report.Spikes)),
+			fmt.Sprint(len(report.InvalidPrices)),
+			fmt.Sprint(len(report.OpenInterest)),
+			fmt.Sprint(len(report.Archive)),
+			fmt.Sprint(len(report.ExcludeRecords)),
+		)
+	}
+	for _, report := range reports {
+		if len(report.ExcludeRecords) == 0 {
+			continue
+		}
+		exclusions := report.ExcludeRecords
+		if len(exclusions) > auditSummaryLimit {
+			fmt.Printf("\n[%s] Suggested excludeRecords (first %d of %d):\n", report.Symbol, auditSummaryLimit, len(exclusions))
+			exclusions = exclusions[:auditSummaryLimit]
+		} else {
+			fmt.Printf("\n[%s] Suggested excludeRecords:\n", report.Symbol)
+		}
+		fmt.Printf("  excludeRecords: [%s]\n", strings.Join(exclusions, ", "))
+	}
+}
\ No newline at end of file
@@ -23,6 +23,7 @@ type BacktestConfiguration struct {
 	DateMax SerializableDate `yaml:"dateMax"`
 	InitialCash *float64 `yaml:"initialCash"`
 	Leverage *float64 `yaml:"leverage"`
+	Timezone string `yaml:"timezone"`
 	Strategies []BacktestStrategy `yaml:"strategies"`
 }
 
@@ -34,6 +35,8 @@ type BacktestStrategy struct {
 	HoldingTime int `yaml:"holdingTime"`
 	Conditions []StrategyCondition `yaml:"conditions"`
 	StopLoss *float64 `yaml:"stopLoss"`
+	TakeProfit *float64 `yaml:"takeProfit"`
+	TrailingStop *float64 `yaml:"trailingStop"`
 }
 
 type StrategyCondition struct {
@@ -60,6 +63,8 @@ type assetRecords struct {
 	dailyRecords []DailyRecord
 	intradayRecords []FeatureRecord
 	recordsMap map[time.Time]*FeatureRecord
+	bars []BarSeries
+	location *time.Location
 }
 
 type strategyCondition struct {
@@ -71,6 +76,7 @@ type strategyCondition struct {
 
 type backtestData struct {
 	symbol string
+	calendar *tradingCalendar
 	conditions []strategyCondition
 	returns returnsAccessor
 	side PositionSide
@@ -86,11 +92,15 @@ type backtestData struct {
 	buyAndHoldSharpe float64
 	tradesRatio float64
 	enabled bool
+	// Set if the strategy was disabled by tradesMin or the strategy filter, which rules out extending it with more conditions
+	filtered bool
 	seasonalityMode bool
 	weekday *time.Weekday
 	enableStopLoss bool
 	stopLoss *float64
 	stopLossHit bool
+	trailingStopHit bool
+	exits *barExits
 }
 
 type backtestComparison struct {
@@ -130,6 +140,7 @@ func Backtest(yamlPath string) {
 		backtestConfig.DateMax,
 		nil,
 		nil,
+		backtestConfig.Timezone,
 	)
 	start := time.Now()
 	comparisons := parallelMap(backtestConfig.Strategies, func (strategy BacktestStrategy) backtestComparison {
@@ -149,39 +160,7 @@ func getSharpeRatioData(comparisons []backtestComparison, buyAndHoldPerformance
 	sharpeOOS := []float64{}
 	for i, comparison := range comparisons {
 		backtest := comparison.completeBacktest
-		var conditionString string
-		if backtest.weekday == nil {
-		conditionStrings := []string{}
-			for _, condition := range backtest.conditions {
-				symbol := condition.asset.asset.Symbol
-				feature := condition.feature.name
-				min := condition.min
-				max := condition.max
-				output := fmt.Sprintf("%s.%s (%.2f, %.2f)", symbol, feature, min, max)
-				conditionStrings = append(conditionStrings, output)
-			}
-			conditionString = strings.Join(conditionStrings, ", ")
-		} else {
-			conditionString = fmt.Sprintf("%s, %s", backtest.symbol, backtest.weekday.String())
-		}
-		side := "long"
-		if backtest.side == SideShort {
-			side = "short"
-		}
-		stopLossString := ""
-		if backtest.enableStopLoss {
-			stopLossString = fmt.Sprintf(", SL %.1f%%", *backtest.stopLoss * 100.0)
-		}
-		format := "%d. %s, %s, %s, %dh%s\n"
-		fmt.Printf(
-			format,
-			i + 1,
-			conditionString,
-			side,
-			getTimeOfDayString(*backtest.timeOfDay),
-			backtest.returns.holdingTime,
-			stopLossString,
-		)
+		fmt.Printf("%d. %s\n", i + 1, backtest.getStrategyString())
 		performance := comparison.completeBacktest.equityCurve.getPerformance(backtestConfig.DateMin.Time, backtestConfig.DateMax.Time)
 		performanceCorrelation := stat.Correlation(performance, buyAndHoldPerformance, nil)
 		fmt.Printf("\tIS SR:               %.2f\n", comparison.isBacktest.sharpe)
@@ -199,6 +178,48 @@ func getSharpeRatioData(comparisons []backtestComparison, buyAndHoldPerformance
 	}
 }
 
+// Strategies are printed in the format parsed by GenerateStrategyYaml
+func (backtest *backtestData) getStrategyString() string {
+	var conditionString string
+	if backtest.weekday == nil {
+		conditionStrings := []string{}
+		for _, condition := range backtest.conditions {
+			symbol := condition.asset.asset.Symbol
+			feature := condition.feature.name
+			min := condition.min
+			max := condition.max
+			output := fmt.Sprintf("%s.%s (%.2f, %.2f)", symbol, feature, min, max)
+			conditionStrings = append(conditionStrings, output)
+		}
+		conditionString = strings.Join(conditionStrings, ", ")
+	} else {
+		conditionString = fmt.Sprintf("%s, %s", backtest.symbol, backtest.weekday.String())
+	}
+	side := "long"
+	if backtest.side == SideShort {
+		side = "short"
+	}
+	stopLossString := ""
+	if backtest.enableStopLoss {
+		stopLossString = fmt.Sprintf(", SL %.1f%%", *backtest.stopLoss * 100.0)
+	}
+	if backtest.exits != nil && backtest.exits.takeProfit != nil {
+		stopLossString += fmt.Sprintf(", TP %.1f%%", *backtest.exits.takeProfit * 100.0)
+	}
+	if backtest.exits != nil && backtest.exits.trailingStop != nil {
+		stopLossString += fmt.Sprintf(", TS %.1f%%", *backtest.exits.trailingStop * 100.0)
+	}
+	format := "%s, %s, %s, %dh%s"
+	return fmt.Sprintf(
+		format,
+		conditionString,
+		side,
+		getTimeOfDayString(*backtest.timeOfDay),
+		backtest.returns.holdingTime,
+		stopLossString,
+	)
+}
+
 func printStats(
 	sharpeData sharpeRatioData,
 	assetRecords []assetRecords,
@@ -297,6 +318,12 @@ func (s *BacktestStrategy) validate() {
 			condition.validate(first)
 		}
 	}
+	exitLimits := []*float64{s.StopLoss, s.TakeProfit, s.TrailingStop}
+	for _, limit := range exitLimits {
+		if limit != nil && (*limit <= 0.0 || *limit >= 1.0) {
+			log.Fatalf("Invalid exit limit in strategy for %s: %.3f", s.Symbol, *limit)
+		}
+	}
 }
 
 func (s *BacktestStrategy) getStrategyAssets(assets []assetRecords) []assetRecords {
@@ -330,6 +357,9 @@ func (s *BacktestStrategy) getConditions(strategyRecords []assetRecords) []strat
 		if !exists {
 			log.Fatalf("Unable to find a feature accessor corresponding to name \"%s\"", configurationCondition.Feature)
 		}
+		if feature.isBounded() && (configurationCondition.Min < 0.0 || configurationCondition.Max > 1.0) {
+			log.Fatalf("Invalid min/max values in condition on %s feature \"%s\" (min = %.2f, max = %.2f)", feature.normalization, feature.name, configurationCondition.Min, configurationCondition.Max)
+		}
 		condition := strategyCondition{
 			asset: asset,
 			feature: feature,
@@ -342,19 +372,28 @@ func (s *BacktestStrategy) getConditions(strategyRecords []assetRecords) []strat
 }
 
 func (s *BacktestStrategy) getReturnsAccessor() returnsAccessor {
-	returnsAccessors := getReturnsAccessors()
-	name := fmt.Sprintf("returns%dH", s.HoldingTime)
-	returnsAccessor, exists := find(returnsAccessors, func (r returnsAccessor) bool {
-		return r.name == name
-	})
+	returnsAccessor, exists := getReturnsAccessor(s.HoldingTime)
 	if !exists {
-		log.Fatalf("Unable to find a returns accessor with the name \"%s\"", name)
+		log.Fatalf("Holding time %dh is not one of the holdingTimes in %s", s.HoldingTime, configurationPath)
 	}
 	return returnsAccessor
 }
 
+func (s *BacktestStrategy) getBarExits(tradedAsset assetRecords) *barExits {
+	if s.StopLoss == nil && s.TakeProfit == nil && s.TrailingStop == nil {
+		return nil
+	}
+	if len(tradedAsset.bars) == 0 {
+		if s.TakeProfit != nil || s.TrailingStop != nil {
+			log.Fatalf("[%s] Take-profits and trailing stops require an archive with hourly bars, regenerate it", s.Symbol)
+		}
+		return nil
+	}
+	return newBarExits(&tradedAsset, s.TakeProfit, s.TrailingStop)
+}
+
 func (c *StrategyCondition) validate(first bool) {
-	if c.Min < 0.0 || c.Max > 1.0 || c.Min > c.Max {
+	if c.Min > c.Max {
 		log.Fatalf("Invalid min/max values in condition (min = %.2f, max = %.2f)", c.Min, c.Max)
 	}
 	if !first && c.Symbol == "" {
@@ -368,6 +407,7 @@ func getAssetRecords(
 	dateMax SerializableDate,
 	timeMin *SerializableDuration,
 	timeMax *SerializableDuration,
+	timezone string,
 ) []assetRecords {
 	assetPaths := getAssetPaths(symbols)
 	start := time.Now()
@@ -378,6 +418,7 @@ func getAssetRecords(
 			dateMax,
 			timeMin,
 			timeMax,
+			timezone,
 		)
 	})
 	delta := time.Since(start)
@@ -394,7 +435,7 @@ func getAssetPaths(symbols []string) []assetPath {
 		}
 		baseSymbol := asset.Symbol
 		for fNumber := 1; fNumber <= fRecords; fNumber++ {
-			path := getArchivePath(baseSymbol, fNumber)
+			path := getLoadPath(getArchivePath(baseSymbol, fNumber))
 			if fNumber >= 2 {
 				asset.Symbol = fmt.Sprintf("%s.F%d", baseSymbol, fNumber)
 			}
@@ -417,8 +458,16 @@ func executeAssetLoader(
 	dateMax SerializableDate,
 	timeMin *SerializableDuration,
 	timeMax *SerializableDuration,
+	timezone string,
 ) assetRecords {
-	archive := readArchive(assetPath.path)
+	header := readArchiveHeader(assetPath.path)
+	mismatches := header.getMismatches(&assetPath.asset)
+	if header.Version == archiveVersion && len(mismatches) > 0 {
+		log.Fatalf("[%s] Archive %s does not match the configuration, regenerate it:\n%s", assetPath.asset.Symbol, assetPath.path, strings.Join(mismatches, "\n"))
+	}
+	archive := readArchiveRange(assetPath.path, dateMin, dateMax)
+	archive.alignColumns()
+	location := getReferenceLocation(timezone, archive.Timezone)
 	dailyRecords := []DailyRecord{}
 	intradayRecords := []FeatureRecord{}
 	recordsMap := map[time.Time]*FeatureRecord{}
@@ -442,8 +491,9 @@ func executeAssetLoader(
 				continue
 			}
 		}
-		isValid = isValidTime(record.Timestamp, timeMin, timeMax)
-		isBuyAndHold := record.Timestamp.Hour() == buyAndHoldTimeOfDay
+		record.localTime = getLocalTime(record.Timestamp, location)
+		isValid = isValidTime(record.localTime, timeMin, timeMax)
+		isBuyAndHold := record.localTime.Hour() == buyAndHoldTimeOfDay
 		if !isValid && !isBuyAndHold {
 			continue
 		}
@@ -455,6 +505,8 @@ func executeAssetLoader(
 		dailyRecords: dailyRecords,
 		intradayRecords: intradayRecords,
 		recordsMap: recordsMap,
+		bars: archive.Bars,
+		location: getLocation(archive.Timezone),
 	}
 }
 
@@ -521,6 +573,7 @@ func performBacktest(
 ) backtestData {
 	backtest := newBacktest(
 		strategy.Symbol,
+		tradedAsset.asset.getCalendar(),
 		strategy.Side.PositionSide,
 		&strategy.Time.Duration,
 		conditions,
@@ -534,6 +587,7 @@ func performBacktest(
 	if strategy.Weekday != nil {
 		backtest.weekday = &strategy.Weekday.Weekday
 	}
+	backtest.exits = strategy.getBarExits(tradedAsset)
 	for i := range intradayRecords {
 		record := &intradayRecords[i]
 		if record.Timestamp.Before(dateMin) || !record.Timestamp.Before(dateMax) {
@@ -552,8 +606,8 @@ func performBacktest(
 				}
 			}
 		} else {
-			weekdayMatch := record.Timestamp.Weekday() == *backtest.weekday
-			timeOfDay := getTimeOfDay(record.Timestamp)
+			weekdayMatch := record.localTime.Weekday() == *backtest.weekday
+			timeOfDay := getTimeOfDay(record.localTime)
 			timeOfDayMatch := timeOfDay == *backtest.timeOfDay
 			match = weekdayMatch && timeOfDayMatch
 		}
@@ -575,7 +629,7 @@ func onConditionMatch(
 		return
 	}
 	if backtest.timeOfDay != nil {
-		timeOfDay := getTimeOfDay(record.Timestamp)
+		timeOfDay := getTimeOfDay(record.localTime)
 		if timeOfDay != *backtest.timeOfDay {
 			return
 		}
@@ -597,18 +651,25 @@ func onConditionMatch(
 		cash = lastSample.cash
 	}
 	delta := returnsRecord.Close2 - returnsRecord.Close1
-	if backtest.enableStopLoss {
+	walked := false
+	if backtest.exits != nil {
+		delta, walked = backtest.exits.walk(record, backtest.returns.holdingTime, backtest)
+		if !walked {
+			delta = returnsRecord.Close2 - returnsRecord.Close1
+		}
+	}
+	if backtest.enableStopLoss && !walked {
 		processStopLoss(&delta,	returnsRecord, backtest)
 	}
 	returns := getAssetReturns(backtest.side, record.Timestamp, delta, true, asset)
 	notionalValue := float64(returnsRecord.Close1) * asset.TickValue
 	percent := returns / notionalValue
-	weekdayIndex := int(record.Timestamp.Weekday()) - 1
+	weekdayIndex := int(record.localTime.Weekday()) - 1
 	bannedDay := backtest.bannedDay
 	if backtest.optimizeWeekdays {
 		optimizeWeekdays(percent, weekdayIndex, backtest)
 	}
-	if bannedDay != nil && record.Timestamp.Weekday() == *bannedDay {
+	if bannedDay != nil && record.localTime.Weekday() == *bannedDay {
 		return
 	}
 	if leverage != nil {
@@ -652,6 +713,7 @@ func (backtest *backtestData) postProcess(
 		dateMax,
 		backtest.equityCurve,
 		intradayRecords,
+		backtest.calendar,
 	)
 	if setSharpe {
 		sharpeSegments := []float64{}
@@ -672,6 +734,7 @@ func (backtest *backtestData) postProcess(
 
 func newBacktest(
 	symbol string,
+	calendar *tradingCalendar,
 	side PositionSide,
 	timeOfDay *time.Duration,
 	conditions []strategyCondition,
@@ -680,6 +743,7 @@ func newBacktest(
 ) backtestData {
 	return backtestData{
 		symbol: symbol,
+		calendar: calendar,
 		conditions: conditions,
 		returns: returns,
 		side: side,
@@ -693,6 +757,7 @@ func newBacktest(
 		enableStopLoss: false,
 		stopLoss: nil,
 		stopLossHit: false,
+		trailingStopHit: false,
 	}
 }
 
@@ -711,6 +776,11 @@ func getBuyAndHold(
 	if !exists {
 		log.Fatalf("Failed to find matching asset records for buy and hold symbol %s", symbol)
 	}
+	buyAndHoldReturns, exists := getReturnsAccessor(getBuyAndHoldHoldingTime())
+	if !exists {
+		log.Fatalf("Failed to find returns for buy and hold holding time %dh", getBuyAndHoldHoldingTime())
+	}
+	holdingTime := time.Duration(buyAndHoldReturns.holdingTime) * time.Hour
 	for _, record := range records.intradayRecords {
 		if dateMin != nil && record.Timestamp.Before(*dateMin) {
 			continue
@@ -718,14 +788,22 @@ func getBuyAndHold(
 		if dateMax != nil && !record.Timestamp.Before(*dateMax) {
 			continue
 		}
-		if record.Timestamp.Hour() != buyAndHoldTimeOfDay || record.Returns24H == nil {
+		returnsRecord := buyAndHoldReturns.get(&record)
+		if record.localTime.Hour() != buyAndHoldTimeOfDay || returnsRecord == nil {
 			continue
 		}
+		if buyAndHoldReturns.holdingTime > hoursPerDay && !equityCurve.empty() {
+			// Longer holding times must not overlap
+			lastSample := equityCurve.samples[len(equityCurve.samples) - 1]
+			if record.Timestamp.Sub(lastSample.timestamp) < holdingTime {
+				continue
+			}
+		}
 		side := SideLong
 		if records.asset.ShortBias {
 			side = SideShort
 		}
-		delta := record.Returns24H.Close2 - record.Returns24H.Close1
+		delta := returnsRecord.Close2 - returnsRecord.Close1
 		returns := getAssetReturns(side, record.Timestamp, delta, false, &records.asset)
 		cash += returns
 		equityCurve.add(record.Timestamp, cash)
@@ -0,0 +1,122 @@
+package sibylla
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUnboundedConditions(t *testing.T) {
+	tests := []struct {
+		name string
+		definition FeatureDefinition
+		min float64
+		max float64
+		values []float64
+	}{
+		{
+			"z-score",
+			FeatureDefinition{Name: "momentum", Normalization: normalizationZScore},
+			-2.0,
+			-1.0,
+			[]float64{-1.5, 0.5, -2.5, 1.5, -1.2, -0.3, 2.0, -1.8},
+		},
+		{
+			"cross-sectional z-score",
+			FeatureDefinition{Name: "dispersion", Type: featureCrossSectionalZScore},
+			-2.0,
+			-1.0,
+			[]float64{-1.5, 0.5, -2.5, 1.5, -1.2, -0.3, 2.0, -1.8},
+		},
+		{
+			"discrete",
+			FeatureDefinition{Name: "dayOfMonth", Type: featureTradingDayOfMonth},
+			3.0,
+			5.0,
+			[]float64{1, 2, 3, 4, 5, 6, 7, 8},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func (t *testing.T) {
+			configuration = &Configuration{
+				QuantileTransform: true,
+				HoldingTimes: []int{1},
+			}
+			featureCatalog = &[]FeatureDefinition{test.definition}
+			riskFreeRate = &riskFreeRateSeries{
+				currency: currencyUSD,
+				months: []int{0},
+				rates: []float64{0.0},
+			}
+			calendars = map[string]*tradingCalendar{
+				"": newDefaultCalendar(),
+			}
+			tradedAsset := assetRecords{
+				asset: Asset{
+					Symbol: "ES",
+					Currency: currencyUSD,
+					TickValue: 12.5,
+				},
+				recordsMap: map[time.Time]*FeatureRecord{},
+			}
+			// One record per weekday at 15:00, three of them within the bounds of the condition
+			timestamp := time.Date(2024, time.January, 1, 15, 0, 0, 0, time.UTC)
+			for _, value := range test.values {
+				for timestamp.Weekday() == time.Saturday || timestamp.Weekday() == time.Sunday {
+					timestamp = timestamp.AddDate(0, 0, 1)
+				}
+				features := make([]float64, len(getFeatureColumns()))
+				features[0] = value
+				record := FeatureRecord{
+					Timestamp: timestamp,
+					localTime: timestamp,
+					Features: features,
+					Returns: []ReturnsRecord{
+						{Valid: true, High: 4010, Low: 3990, Close1: 4000, Close2: 4004},
+					},
+				}
+				tradedAsset.intradayRecords = append(tradedAsset.intradayRecords, record)
+				timestamp = timestamp.AddDate(0, 0, 1)
+			}
+			for i := range tradedAsset.intradayRecords {
+				record := &tradedAsset.intradayRecords[i]
+				tradedAsset.recordsMap[record.Timestamp] = record
+			}
+			strategy := BacktestStrategy{
+				Symbol: "ES",
+				Side: SerializableSide{SideLong},
+				Time: SerializableDuration{15 * time.Hour},
+				HoldingTime: 1,
+				Conditions: []StrategyCondition{
+					{Feature: test.definition.Name, Min: test.min, Max: test.max},
+				},
+			}
+			strategy.Conditions[0].validate(true)
+			conditions := strategy.getConditions([]assetRecords{tradedAsset})
+			initialCash := 100000.0
+			backtestConfig := BacktestConfiguration{
+				DateMin: SerializableDate{time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)},
+				DateMax: SerializableDate{time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)},
+				InitialCash: &initialCash,
+			}
+			returns := strategy.getReturnsAccessor()
+			backtest := performBacktest(
+				backtestConfig.DateMin.Time,
+				backtestConfig.DateMax.Time,
+				returns,
+				tradedAsset.intradayRecords,
+				tradedAsset,
+				conditions,
+				strategy,
+				backtestConfig,
+			)
+			expected := 3
+			trades := 0
+			for _, weekdayReturns := range backtest.weekdayReturns {
+				trades += len(weekdayReturns)
+			}
+			if trades != expected {
+				t.Errorf("Condition (%.1f, %.1f) matched %d records, expected %d", test.min, test.max, trades, expected)
+			}
+		})
+	}
+}
\ No newline at end of file
@@ -0,0 +1,256 @@
+package sibylla
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Hourly bars of one contract from the first time it was selected until the longest holding time after it was last selected.
+// Timestamps are UTC close times in Unix seconds, prices are stored in ticks.
+type BarSeries struct {
+	Symbol string
+	SelectedUntil int64
+	Timestamps []int64
+	Open []int32
+	High []int32
+	Low []int32
+	Close []int32
+	Volume []float32
+}
+
+type barExits struct {
+	bars []BarSeries
+	location *time.Location
+	calendar *tradingCalendar
+	takeProfit *float64
+	trailingStop *float64
+}
+
+type contractSelection struct {
+	symbol GlobexCode
+	first time.Time
+	last time.Time
+}
+
+func getBarSeries(
+	intradayTimestamps []time.Time,
+	dailyMap dailyRecordMap,
+	intradayRecords intradayRecordsMap,
+	location *time.Location,
+	asset *Asset,
+) []BarSeries {
+	calendar := asset.getCalendar()
+	selections := []contractSelection{}
+	for _, timestamp := range intradayTimestamps {
+		record, exists := dailyMap[calendar.getSessionDate(timestamp)]
+		if !exists {
+			continue
+		}
+		_, exists = intradayRecords[getGlobexTimeKey(record.symbol, timestamp)]
+		if !exists {
+			continue
+		}
+		length := len(selections)
+		if length > 0 && selections[length - 1].symbol == record.symbol {
+			selections[length - 1].last = timestamp
+		} else {
+			selection := contractSelection{
+				symbol: record.symbol,
+				first: timestamp,
+				last: timestamp,
+			}
+			selections = append(selections, selection)
+		}
+	}
+	maxReturnsDays := getMaxReturnsDays()
+	getTicks := func (value float64) int32 {
+		return int32(value / asset.TickSize)
+	}
+	getUnix := func (timestamp time.Time) int64 {
+		return getUTCTime(getCloseTimestamp(timestamp), location).Unix()
+	}
+	output := []BarSeries{}
+	for _, selection := range selections {
+		end := getAdjustedTimestamp(maxReturnsDays, 0, selection.last, calendar)
+		series := BarSeries{
+			Symbol: selection.symbol.String(),
+			SelectedUntil: getUnix(selection.last),
+		}
+		start := sort.Search(len(intradayTimestamps), func (i int) bool {
+			return !intradayTimestamps[i].Before(selection.first)
+		})
+		for _, timestamp := range intradayTimestamps[start:] {
+			if timestamp.After(end) {
+				break
+			}
+			record, exists := intradayRecords[getGlobexTimeKey(selection.symbol, timestamp)]
+			if !exists {
+				continue
+			}
+			series.Timestamps = append(series.Timestamps, getUnix(timestamp))
+			series.Open = append(series.Open, getTicks(record.open))
+			series.High = append(series.High, getTicks(record.high))
+			series.Low = append(series.Low, getTicks(record.low))
+			series.Close = append(series.Close, getTicks(record.close))
+			series.Volume = append(series.Volume, float32(record.volume))
+		}
+		output = append(output, series)
+	}
+	return output
+}
+
+// Bars of contracts that were already part of the archive are only replaced from the first generated timestamp onwards
+func mergeBarSeries(previous, generated []BarSeries) []BarSeries {
+	if len(generated) == 0 {
+		return previous
+	}
+	output := []BarSeries{}
+	for _, series := range previous {
+		if series.SelectedUntil < generated[0].Timestamps[0] {
+			output = append(output, series)
+			continue
+		}
+		for i := range generated {
+			next := &generated[i]
+			if next.Symbol != series.Symbol || len(next.Timestamps) == 0 {
+				continue
+			}
+			index := sort.Search(len(series.Timestamps), func (j int) bool {
+				return series.Timestamps[j] >= next.Timestamps[0]
+			})
+			next.Timestamps = append(series.Timestamps[:index:index], next.Timestamps...)
+			next.Open = append(series.Open[:index:index], next.Open...)
+			next.High = append(series.High[:index:index], next.High...)
+			next.Low = append(series.Low[:index:index], next.Low...)
+			next.Close = append(series.Close[:index:index], next.Close...)
+			next.Volume = append(series.Volume[:index:index], next.Volume...)
+			break
+		}
+	}
+	output = append(output, generated...)
+	return output
+}
+
+func (s *BarSeries) getIndex(timestamp int64) (int, bool) {
+	index := sort.Search(len(s.Timestamps), func (i int) bool {
+		return s.Timestamps[i] >= timestamp
+	})
+	found := index < len(s.Timestamps) && s.Timestamps[index] == timestamp
+	return index, found
+}
+
+func (e *barExits) getSeries(timestamp int64) (*BarSeries, int, bool) {
+	for i := len(e.bars) - 1; i >= 0; i-- {
+		series := &e.bars[i]
+		if len(series.Timestamps) == 0 || series.Timestamps[0] > timestamp || series.SelectedUntil < timestamp {
+			continue
+		}
+		index, found := series.getIndex(timestamp)
+		if found {
+			return series, index, true
+		}
+	}
+	return nil, 0, false
+}
+
+func newBarExits(tradedAsset *assetRecords, takeProfit *float64, trailingStop *float64) *barExits {
+	exits := barExits{
+		bars: tradedAsset.bars,
+		location: tradedAsset.location,
+		calendar: tradedAsset.asset.getCalendar(),
+		takeProfit: takeProfit,
+		trailingStop: trailingStop,
+	}
+	return &exits
+}
+
+// Walks the bars of the contract entered at the close of the record until the holding time elapses.
+// If a bar reaches both the stop and the take-profit level, the stop is assumed to have been hit first.
+// Returns the delta in ticks and false if the bars required are missing from the archive or end before the holding time elapses.
+func (e *barExits) walk(record *FeatureRecord, holdingTime int, backtest *backtestData) (int, bool) {
+	entryTimestamp := record.Timestamp.Unix()
+	series, index, exists := e.getSeries(entryTimestamp)
+	if !exists {
+		return 0, false
+	}
+	localEntry := getLocalTime(record.Timestamp, e.location)
+	localExit := getAdjustedTimestamp(holdingTime / hoursPerDay, holdingTime % hoursPerDay, localEntry, e.calendar)
+	exitTimestamp := getUTCTime(localExit, e.location).Unix()
+	long := backtest.side == SideLong
+	direction := 1.0
+	if !long {
+		direction = -1.0
+	}
+	entry := series.Close[index]
+	getLevel := func (reference int32, percentage float64) float64 {
+		return float64(reference) * (1.0 + direction * percentage)
+	}
+	var stopLevel *float64
+	if backtest.enableStopLoss {
+		level := getLevel(entry, - *backtest.stopLoss)
+		stopLevel = &level
+	}
+	var takeProfitLevel *float64
+	if e.takeProfit != nil {
+		level := getLevel(entry, *e.takeProfit)
+		takeProfitLevel = &level
+	}
+	extreme := entry
+	exit := entry
+	i := index + 1
+	for ; i < len(series.Timestamps) && series.Timestamps[i] <= exitTimestamp; i++ {
+		open := float64(series.Open[i])
+		high := float64(series.High[i])
+		low := float64(series.Low[i])
+		level := stopLevel
+		trailing := false
+		if e.trailingStop != nil {
+			trailingLevel := getLevel(extreme, - *e.trailingStop)
+			if level == nil || direction * (trailingLevel - *level) > 0 {
+				level = &trailingLevel
+				trailing = true
+			}
+		}
+		if level != nil {
+			stopHit := false
+			var delta int
+			if long && low <= *level {
+				delta = int(math.Min(open, *level)) - stopLossSlippage - int(entry)
+				stopHit = true
+			} else if !long && high >= *level {
+				delta = int(math.Max(open, *level)) + stopLossSlippage - int(entry)
+				stopHit = true
+			}
+			if stopHit {
+				// Trailing stops are tracked separately so that they do not count towards stop-loss statistics
+				if trailing {
+					backtest.trailingStopHit = true
+				} else {
+					backtest.stopLossHit = true
+				}
+				return delta, true
+			}
+		}
+		if takeProfitLevel != nil {
+			if long && high >= *takeProfitLevel {
+				fill := math.Max(open, *takeProfitLevel)
+				return int(fill) - int(entry), true
+			} else if !long && low <= *takeProfitLevel {
+				fill := math.Min(open, *takeProfitLevel)
+				return int(fill) - int(entry), true
+			}
+		}
+		if long {
+			extreme = max(extreme, series.High[i])
+		} else {
+			extreme = min(extreme, series.Low[i])
+		}
+		exit = series.Close[i]
+	}
+	if i == len(series.Timestamps) && series.Timestamps[i - 1] < exitTimestamp {
+		// The archive ends before the holding time elapses
+		return 0, false
+	}
+	return int(exit - entry), true
+}
\ No newline at end of file
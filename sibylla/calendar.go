@@ -0,0 +1,181 @@
+package sibylla
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const daysPerFullWeek = 7
+const defaultCalendarPath = "configuration/calendars"
+
+type CalendarConfiguration struct {
+	Weekdays []SerializableWeekday `yaml:"weekdays"`
+	SessionOpen *SerializableDuration `yaml:"sessionOpen"`
+	SessionClose *SerializableDuration `yaml:"sessionClose"`
+	Holidays []SerializableDate `yaml:"holidays"`
+	EarlyCloses []EarlyClose `yaml:"earlyCloses"`
+}
+
+type EarlyClose struct {
+	Date SerializableDate `yaml:"date"`
+	Close SerializableDuration `yaml:"close"`
+}
+
+type tradingCalendar struct {
+	name string
+	weekdays [daysPerFullWeek]bool
+	sessionOpen *time.Duration
+	sessionClose *time.Duration
+	holidays map[time.Time]struct{}
+	earlyCloses map[time.Time]time.Duration
+}
+
+var calendars map[string]*tradingCalendar
+
+func loadCalendars() {
+	if calendars != nil {
+		panic("Calendars had already been loaded")
+	}
+	calendars = map[string]*tradingCalendar{
+		"": newDefaultCalendar(),
+	}
+	for _, asset := range *assets {
+		_, exists := calendars[asset.Calendar]
+		if !exists {
+			calendars[asset.Calendar] = loadCalendar(asset.Calendar)
+		}
+	}
+}
+
+func newDefaultCalendar() *tradingCalendar {
+	calendar := tradingCalendar{
+		name: "default",
+		holidays: map[time.Time]struct{}{},
+		earlyCloses: map[time.Time]time.Duration{},
+	}
+	for weekday := time.Monday; weekday <= time.Friday; weekday++ {
+		calendar.weekdays[weekday] = true
+	}
+	return &calendar
+}
+
+func loadCalendar(name string) *tradingCalendar {
+	fileName := fmt.Sprintf("%s.yaml", name)
+	directory := defaultCalendarPath
+	if configuration.CalendarPath != "" {
+		directory = configuration.CalendarPath
+	}
+	path := filepath.Join(directory, fileName)
+	yamlData := readFile(path)
+	calendarConfig := new(CalendarConfiguration)
+	err := yaml.Unmarshal(yamlData, calendarConfig)
+	if err != nil {
+		log.Fatalf("Failed to unmarshal calendar YAML (%s): %v", path, err)
+	}
+	if len(calendarConfig.Weekdays) == 0 {
+		log.Fatalf("No weekdays defined in calendar %s", path)
+	}
+	if (calendarConfig.SessionOpen == nil) != (calendarConfig.SessionClose == nil) {
+		log.Fatalf("Calendar %s must define both sessionOpen and sessionClose", path)
+	}
+	calendar := tradingCalendar{
+		name: name,
+		holidays: map[time.Time]struct{}{},
+		earlyCloses: map[time.Time]time.Duration{},
+	}
+	for _, weekday := range calendarConfig.Weekdays {
+		calendar.weekdays[weekday.Weekday] = true
+	}
+	if calendarConfig.SessionOpen != nil {
+		calendar.sessionOpen = &calendarConfig.SessionOpen.Duration
+		calendar.sessionClose = &calendarConfig.SessionClose.Duration
+	}
+	for _, holiday := range calendarConfig.Holidays {
+		calendar.holidays[holiday.Time] = struct{}{}
+	}
+	for _, earlyClose := range calendarConfig.EarlyCloses {
+		calendar.earlyCloses[earlyClose.Date.Time] = earlyClose.Close.Duration
+	}
+	return &calendar
+}
+
+func (a *Asset) getCalendar() *tradingCalendar {
+	calendar, exists := calendars[a.Calendar]
+	if !exists {
+		log.Fatalf("[%s] Calendar \"%s\" has not been loaded", a.Symbol, a.Calendar)
+	}
+	return calendar
+}
+
+func (c *tradingCalendar) isTradingDay(date time.Time) bool {
+	if !c.weekdays[date.Weekday()] {
+		return false
+	}
+	_, isHoliday := c.holidays[getDateFromTime(date)]
+	return !isHoliday
+}
+
+// Sessions that wrap past midnight belong to the trading day on which they close
+func (c *tradingCalendar) getSessionDate(timestamp time.Time) time.Time {
+	date := getDateFromTime(timestamp)
+	if c.sessionOpen != nil && *c.sessionOpen >= *c.sessionClose && getTimeOfDay(timestamp) >= *c.sessionOpen {
+		return date.AddDate(0, 0, 1)
+	}
+	return date
+}
+
+func (c *tradingCalendar) isTradingTime(timestamp time.Time) bool {
+	date := c.getSessionDate(timestamp)
+	if !c.isTradingDay(date) {
+		return false
+	}
+	timeOfDay := getTimeOfDay(timestamp)
+	if c.sessionOpen == nil {
+		earlyClose, exists := c.earlyCloses[date]
+		return !exists || timeOfDay < earlyClose
+	}
+	open := *c.sessionOpen
+	close := *c.sessionClose
+	if date.After(getDateFromTime(timestamp)) {
+		// Evening part of a session that wraps past midnight, early closes only apply to the day on which it closes
+		return true
+	}
+	earlyClose, exists := c.earlyCloses[date]
+	if exists && timeOfDay >= earlyClose {
+		return false
+	}
+	if open < close {
+		return timeOfDay >= open && timeOfDay < close
+	} else {
+		return timeOfDay < close
+	}
+}
+
+func (c *tradingCalendar) addTradingDays(timestamp time.Time, days int) time.Time {
+	direction := 1
+	if days < 0 {
+		days = -days
+		direction = -1
+	}
+	for range days {
+		timestamp = timestamp.AddDate(0, 0, direction)
+		for !c.isTradingDay(timestamp) {
+			timestamp = timestamp.AddDate(0, 0, direction)
+		}
+	}
+	return timestamp
+}
+
+func (c *tradingCalendar) countTradingDays(start, end time.Time) int {
+	count := 0
+	for date := getDateFromTime(start); date.Before(end); date = date.AddDate(0, 0, 1) {
+		if c.isTradingDay(date) {
+			count++
+		}
+	}
+	return count
+}
\ No newline at end of file
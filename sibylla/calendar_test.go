@@ -0,0 +1,48 @@
+package sibylla
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionWrappingPastMidnight(t *testing.T) {
+	open := 17 * time.Hour
+	close := 16 * time.Hour
+	calendar := newDefaultCalendar()
+	calendar.sessionOpen = &open
+	calendar.sessionClose = &close
+	// Independence Day on a Thursday, early close on the Wednesday before it
+	holiday := time.Date(2024, time.July, 4, 0, 0, 0, 0, time.UTC)
+	earlyCloseDate := time.Date(2024, time.July, 3, 0, 0, 0, 0, time.UTC)
+	calendar.holidays[holiday] = struct{}{}
+	calendar.earlyCloses[earlyCloseDate] = 12 * time.Hour
+	getTimestamp := func (day, hour int) time.Time {
+		return time.Date(2024, time.July, day, hour, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name string
+		timestamp time.Time
+		expected bool
+	}{
+		{"Sunday evening", getTimestamp(7, 17), true},
+		{"Sunday before the open", getTimestamp(7, 15), false},
+		{"Monday morning", getTimestamp(8, 9), true},
+		{"Monday maintenance break", getTimestamp(8, 16), false},
+		{"Friday afternoon", getTimestamp(12, 15), true},
+		{"Friday evening", getTimestamp(12, 17), false},
+		{"Saturday", getTimestamp(13, 10), false},
+		{"Evening before early close", getTimestamp(2, 20), true},
+		{"Before early close", getTimestamp(3, 11), true},
+		{"After early close", getTimestamp(3, 13), false},
+		{"Evening before holiday", getTimestamp(3, 18), false},
+		{"Holiday", getTimestamp(4, 10), false},
+		{"Evening of holiday", getTimestamp(4, 17), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func (t *testing.T) {
+			if calendar.isTradingTime(test.timestamp) != test.expected {
+				t.Errorf("isTradingTime(%s) = %t, expected %t", getTimeString(test.timestamp), !test.expected, test.expected)
+			}
+		})
+	}
+}
\ No newline at end of file
@@ -0,0 +1,365 @@
+package sibylla
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const featuresPath = "configuration/features.yaml"
+const featureMomentum = "momentum"
+const featureVolatility = "volatility"
+const featureAtr = "atr"
+const featureRangePercentile = "rangePercentile"
+const featureRsi = "rsi"
+const featureDistanceHigh = "distanceHigh"
+const featureDistanceLow = "distanceLow"
+const featureMomentumZScore = "momentumZScore"
+const featureRelativeVolume = "relativeVolume"
+const featureVolumeMomentum = "volumeMomentum"
+const featureOpenInterestChange = "openInterestChange"
+const featureCrossSectionalRank = "crossSectionalRank"
+const featureCrossSectionalZScore = "crossSectionalZScore"
+const hoursPerDay = 24
+const rawFeatureSuffix = "Raw"
+
+const (
+	normalizationRank = "rank"
+	normalizationAnchoredRank = "anchoredRank"
+	normalizationZScore = "zScore"
+	normalizationRaw = "raw"
+)
+
+type FeatureDefinition struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+	Days int `yaml:"days"`
+	Hours int `yaml:"hours"`
+	LagDays int `yaml:"lagDays"`
+	Window int `yaml:"window"`
+	Feature string `yaml:"feature"`
+	Group string `yaml:"group"`
+	Event string `yaml:"event"`
+	Normalization string `yaml:"normalization"`
+	kind *featureKind
+}
+
+// Normalized features are followed by a column with their raw values
+type featureColumn struct {
+	name string
+	normalization string
+	discrete bool
+}
+
+type featureKind struct {
+	validate func (definition *FeatureDefinition) error
+	compute func (definition *FeatureDefinition, context *featureContext) *float64
+	lookbackDays func (definition *FeatureDefinition) int
+}
+
+type featureContext struct {
+	timestamp time.Time
+	record intradayRecord
+	series priceSeries
+	dailyCloses dailyCloseMap
+	dailyOpenInterest dailyOpenInterestMap
+	intradayRecords intradayRecordsMap
+	dailyRanges dailyRangeMap
+	calendar *tradingCalendar
+	asset *Asset
+}
+
+var featureCatalog *[]FeatureDefinition
+
+func getFeatureKinds() map[string]*featureKind {
+	return map[string]*featureKind{
+		featureMomentum: {
+			validate: func (definition *FeatureDefinition) error {
+				if definition.Days <= 0 && definition.Hours <= 0 {
+					return fmt.Errorf("momentum requires days or hours")
+				}
+				if definition.LagDays >= definition.Days && definition.LagDays > 0 {
+					return fmt.Errorf("lagDays must be less than days")
+				}
+				return nil
+			},
+			compute: func (definition *FeatureDefinition, context *featureContext) *float64 {
+				return getMomentum(definition.Days, definition.LagDays, definition.Hours, context)
+			},
+		},
+		featureVolatility: {
+			validate: validateBars,
+			compute: getRealizedVolatility,
+		},
+		featureAtr: {
+			validate: validateBars,
+			compute: getAverageTrueRange,
+		},
+		featureRangePercentile: {
+			validate: validateBars,
+			compute: getRangePercentile,
+		},
+		featureRsi: {
+			validate: validateBars,
+			compute: getRelativeStrengthIndex,
+		},
+		featureDistanceHigh: {
+			validate: validateBars,
+			compute: func (definition *FeatureDefinition, context *featureContext) *float64 {
+				return getDistanceFromExtreme(true, definition, context)
+			},
+		},
+		featureDistanceLow: {
+			validate: validateBars,
+			compute: func (definition *FeatureDefinition, context *featureContext) *float64 {
+				return getDistanceFromExtreme(false, definition, context)
+			},
+		},
+		featureMomentumZScore: {
+			validate: func (definition *FeatureDefinition) error {
+				err := validateBars(definition)
+				if err != nil {
+					return err
+				}
+				if definition.Window < 2 {
+					return fmt.Errorf("momentumZScore requires a window of at least 2")
+				}
+				return nil
+			},
+			compute: getMomentumZScore,
+		},
+		featureRelativeVolume: {
+			validate: validateDays,
+			compute: getRelativeVolume,
+		},
+		featureVolumeMomentum: {
+			validate: validateBars,
+			compute: getVolumeMomentum,
+			lookbackDays: func (definition *FeatureDefinition) int {
+				return (2 * definition.Days) + (2 * definition.Hours + hoursPerDay - 1) / hoursPerDay
+			},
+		},
+		featureOpenInterestChange: {
+			validate: validateDays,
+			compute: getOpenInterestChange,
+			lookbackDays: func (definition *FeatureDefinition) int {
+				return definition.Days + 1
+			},
+		},
+		featureTradingDayOfMonth: {
+			validate: validateCalendarFeature,
+			compute: getTradingDayOfMonth,
+		},
+		featureTradingDaysToMonthEnd: {
+			validate: validateCalendarFeature,
+			compute: getTradingDaysToMonthEnd,
+		},
+		featureDaysToExpiry: {
+			validate: validateCalendarFeature,
+			compute: getDaysToExpiry,
+		},
+		featureEvent: {
+			validate: validateEventFeature,
+			compute: getEventFlag,
+		},
+		featureCrossSectionalRank: {
+			validate: validateCrossSectional,
+			compute: getCrossSectionalPlaceholder,
+		},
+		featureCrossSectionalZScore: {
+			validate: validateCrossSectional,
+			compute: getCrossSectionalPlaceholder,
+		},
+	}
+}
+
+func loadFeatureCatalog() {
+	if featureCatalog != nil {
+		panic("Feature catalog had already been loaded")
+	}
+	yamlData := readFile(featuresPath)
+	featureCatalog = new([]FeatureDefinition)
+	err := yaml.Unmarshal(yamlData, featureCatalog)
+	if err != nil {
+		log.Fatal("Failed to unmarshal YAML:", err)
+	}
+	kinds := getFeatureKinds()
+	names := map[string]struct{}{}
+	for i := range *featureCatalog {
+		definition := &(*featureCatalog)[i]
+		if definition.Name == "" {
+			log.Fatalf("Missing name in feature definition %d in %s", i + 1, featuresPath)
+		}
+		_, exists := names[definition.Name]
+		if exists {
+			log.Fatalf("Duplicate feature \"%s\" in %s", definition.Name, featuresPath)
+		}
+		names[definition.Name] = struct{}{}
+		kind, exists := kinds[definition.Type]
+		if !exists {
+			log.Fatalf("Unknown type \"%s\" in feature \"%s\"", definition.Type, definition.Name)
+		}
+		if definition.Days < 0 || definition.Hours < 0 || definition.LagDays < 0 || definition.Window < 0 {
+			log.Fatalf("Negative lookback in feature \"%s\"", definition.Name)
+		}
+		err := kind.validate(definition)
+		if err != nil {
+			log.Fatalf("Invalid feature \"%s\": %v", definition.Name, err)
+		}
+		normalizations := []string{
+			"",
+			normalizationRank,
+			normalizationAnchoredRank,
+			normalizationZScore,
+			normalizationRaw,
+		}
+		if !slices.Contains(normalizations, definition.Normalization) {
+			log.Fatalf("Unknown normalization \"%s\" in feature \"%s\"", definition.Normalization, definition.Name)
+		}
+		definition.kind = kind
+	}
+	validateCrossSectionalSources()
+	columnNames := getFeatureNames()
+	for i, name := range columnNames {
+		if slices.Contains(columnNames[:i], name) {
+			log.Fatalf("Feature \"%s\" conflicts with the raw column of another feature", name)
+		}
+	}
+}
+
+func (d *FeatureDefinition) getNormalization() string {
+	if d.isCrossSectional() || d.isDiscrete() {
+		return normalizationRaw
+	}
+	if d.Normalization == "" {
+		return normalizationRank
+	}
+	return d.Normalization
+}
+
+func (d *FeatureDefinition) hasRawColumn() bool {
+	return configuration.QuantileTransform && d.getNormalization() != normalizationRaw
+}
+
+func getFeatureColumns() []featureColumn {
+	columns := []featureColumn{}
+	for _, definition := range *featureCatalog {
+		normalization := normalizationRaw
+		if configuration.QuantileTransform {
+			normalization = definition.getNormalization()
+		}
+		column := featureColumn{
+			name: definition.Name,
+			normalization: normalization,
+			discrete: definition.isDiscrete(),
+		}
+		columns = append(columns, column)
+		if definition.hasRawColumn() {
+			rawColumn := featureColumn{
+				name: definition.Name + rawFeatureSuffix,
+				normalization: normalizationRaw,
+			}
+			columns = append(columns, rawColumn)
+		}
+	}
+	return columns
+}
+
+func getFeatureNames() []string {
+	names := []string{}
+	for _, column := range getFeatureColumns() {
+		names = append(names, column.name)
+	}
+	return names
+}
+
+func getMaxLookbackDays() int {
+	lookbackDays := 0
+	for _, definition := range *featureCatalog {
+		lookbackDays = max(lookbackDays, definition.getLookbackDays())
+	}
+	return lookbackDays
+}
+
+func (d *FeatureDefinition) getLookbackDays() int {
+	if d.kind.lookbackDays != nil {
+		return d.kind.lookbackDays(d)
+	}
+	if d.Days > 0 {
+		return max(d.Days, d.Window)
+	}
+	return (max(d.Hours, d.Window) + hoursPerDay - 1) / hoursPerDay
+}
+
+func getFeatureValues(context *featureContext) []float64 {
+	values := []float64{}
+	for i := range *featureCatalog {
+		definition := &(*featureCatalog)[i]
+		value := math.NaN()
+		pointer := definition.kind.compute(definition, context)
+		if pointer != nil {
+			value = *pointer
+		}
+		values = append(values, value)
+		if definition.hasRawColumn() {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// Archives store features by name and returns by holding time, missing features are filled with NaN
+func (a *Archive) alignColumns() {
+	a.alignFeatures()
+	a.alignReturns()
+}
+
+func (a *Archive) alignFeatures() {
+	names := getFeatureNames()
+	if slices.Equal(a.Features, names) {
+		return
+	}
+	indexes := []int{}
+	for _, name := range names {
+		indexes = append(indexes, slices.Index(a.Features, name))
+	}
+	for i := range a.IntradayRecords {
+		record := &a.IntradayRecords[i]
+		values := make([]float64, len(names))
+		for j, index := range indexes {
+			if index >= 0 && index < len(record.Features) {
+				values[j] = record.Features[index]
+			} else {
+				values[j] = math.NaN()
+			}
+		}
+		record.Features = values
+	}
+	a.Features = names
+}
+
+func (a *Archive) alignReturns() {
+	holdingTimes := getHoldingTimes()
+	if slices.Equal(a.HoldingTimes, holdingTimes) {
+		return
+	}
+	indexes := []int{}
+	for _, holdingTime := range holdingTimes {
+		indexes = append(indexes, slices.Index(a.HoldingTimes, holdingTime))
+	}
+	for i := range a.IntradayRecords {
+		record := &a.IntradayRecords[i]
+		returns := make([]ReturnsRecord, len(holdingTimes))
+		for j, index := range indexes {
+			if index >= 0 && index < len(record.Returns) {
+				returns[j] = record.Returns[index]
+			}
+		}
+		record.Returns = returns
+	}
+	a.HoldingTimes = holdingTimes
+}
\ No newline at end of file
@@ -0,0 +1,321 @@
+package sibylla
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const causalityDefaultPath = "causality.json"
+const causalityDefaultSamples = 100
+const causalityExampleLimit = 10
+const causalityTolerance = 1e-9
+
+type CausalityReport struct {
+	Symbol string `json:"symbol"`
+	Samples int `json:"samples"`
+	Features []CausalityIssue `json:"features"`
+	Quantiles []CausalityIssue `json:"quantiles"`
+}
+
+type CausalityIssue struct {
+	Name string `json:"name"`
+	Checked int `json:"checked"`
+	Mismatches int `json:"mismatches"`
+	Examples []CausalityExample `json:"examples"`
+}
+
+type CausalityExample struct {
+	Time string `json:"time"`
+	Archive *float64 `json:"archive"`
+	Causal *float64 `json:"causal"`
+}
+
+type causalityData struct {
+	asset Asset
+	daily readDailyRecordsResult
+	// Sorted by timestamp so that the bars up to each sample form a prefix
+	intradayRecords []causalityIntradayRecord
+	location *time.Location
+}
+
+type causalityIntradayRecord struct {
+	key globexTimeKey
+	record intradayRecord
+}
+
+func VerifyCausality(symbol string) {
+	loadConfiguration()
+	start := time.Now()
+	verifyAssets := []Asset{}
+	for _, asset := range *assets {
+		if symbol == "all" || asset.Symbol == symbol {
+			verifyAssets = append(verifyAssets, asset)
+		}
+	}
+	if len(verifyAssets) == 0 {
+		log.Fatalf("Unable to find an asset matching symbol %s", symbol)
+	}
+	reports := []CausalityReport{}
+	for _, asset := range verifyAssets {
+		reports = append(reports, verifyAssetCausality(asset)...)
+	}
+	jsonBytes, err := json.MarshalIndent(reports, "", "\t")
+	if err != nil {
+		log.Fatal("Failed to serialize causality report to JSON:", err)
+	}
+	path := causalityDefaultPath
+	if configuration.CausalityPath != "" {
+		path = configuration.CausalityPath
+	}
+	writeFile(path, string(jsonBytes))
+	delta := time.Since(start)
+	fmt.Printf("Verified %d archives in %.2f s\n\n", len(reports), delta.Seconds())
+	printCausalitySummary(reports)
+	fmt.Printf("\nWrote causality report to %s\n", path)
+}
+
+func verifyAssetCausality(asset Asset) []CausalityReport {
+	source := asset.getDataSource()
+	intradayRecords := []causalityIntradayRecord{}
+	for key, record := range readIntradayRecords(asset, source, time.Time{}) {
+		intradayRecords = append(intradayRecords, causalityIntradayRecord{key: key, record: record})
+	}
+	sort.Slice(intradayRecords, func (i, j int) bool {
+		return intradayRecords[i].key.timestamp.Before(intradayRecords[j].key.timestamp)
+	})
+	data := causalityData{
+		asset: asset,
+		daily: readDailyRecords(asset, source, time.Time{}),
+		intradayRecords: intradayRecords,
+		location: asset.getLocation(),
+	}
+	fLimit := 1
+	if asset.FRecords != nil {
+		fLimit = *asset.FRecords
+	}
+	reports := []CausalityReport{}
+	for fNumber := 1; fNumber <= fLimit; fNumber++ {
+		path := getArchivePath(asset.Symbol, fNumber)
+		_, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			fmt.Printf("[%s] Archive does not exist, skipping: %s\n", asset.Symbol, path)
+			continue
+		}
+		header := readArchiveHeader(path)
+		mismatches := header.getMismatches(&asset)
+		if header.Version != archiveVersion || len(mismatches) > 0 {
+			log.Fatalf("[%s] Archive %s does not match the configuration, regenerate it before verifying it", asset.Symbol, path)
+		}
+		archive := readArchive(path)
+		if !slices.Equal(archive.Features, getFeatureNames()) {
+			log.Fatalf("[%s] Feature catalog changed, regenerate %s before verifying it", asset.Symbol, path)
+		}
+		report := data.verifyArchive(fNumber, &archive)
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+func (d *causalityData) verifyArchive(fNumber int, archive *Archive) CausalityReport {
+	samples := causalityDefaultSamples
+	if configuration.CausalitySamples > 0 {
+		samples = configuration.CausalitySamples
+	}
+	records := archive.IntradayRecords
+	indexes := []int{}
+	for i := range samples {
+		index := (i + 1) * len(records) / (samples + 1)
+		if len(indexes) == 0 || indexes[len(indexes) - 1] != index {
+			indexes = append(indexes, index)
+		}
+	}
+	symbol := archive.Symbol
+	if fNumber >= 2 {
+		symbol = fmt.Sprintf("%s.F%d", archive.Symbol, fNumber)
+	}
+	fmt.Printf("[%s] Regenerating features at %d sampled timestamps\n", symbol, len(indexes))
+	// The samples are in chronological order, each one extends the bars of the previous one by the next slice of records
+	regenerated := [][]float64{}
+	intradayRecords := intradayRecordsMap{}
+	dailyRanges := dailyRangeMap{}
+	next := 0
+	for _, index := range indexes {
+		timestamp := getLocalTime(records[index].Timestamp, d.location).Add(-time.Hour)
+		for next < len(d.intradayRecords) && !d.intradayRecords[next].key.timestamp.After(timestamp) {
+			intradayRecord := &d.intradayRecords[next]
+			intradayRecords[intradayRecord.key] = intradayRecord.record
+			dailyRanges.add(intradayRecord.key, intradayRecord.record)
+			next++
+		}
+		features := d.regenerateFeatures(fNumber, timestamp, intradayRecords, dailyRanges)
+		regenerated = append(regenerated, features)
+	}
+	report := CausalityReport{
+		Symbol: symbol,
+		Samples: len(indexes),
+		Features: []CausalityIssue{},
+		Quantiles: []CausalityIssue{},
+	}
+	normalized := d.normalizeRecords(indexes, archive)
+	for i, accessor := range getFeatureAccessors() {
+		definition, exists := find(*featureCatalog, func (d FeatureDefinition) bool {
+			return d.Name == accessor.name
+		})
+		if exists && definition.isCrossSectional() {
+			// Cross-sectional features only depend on values of other assets at the same time
+			continue
+		}
+		// Raw columns are compared to the regenerated values, normalized columns to a causal normalization of the raw columns
+		if accessor.normalization == normalizationRaw {
+			issue := CausalityIssue{
+				Name: accessor.name,
+				Examples: []CausalityExample{},
+			}
+			for j, index := range indexes {
+				var causal *float64
+				if regenerated[j] != nil && !math.IsNaN(regenerated[j][i]) {
+					causal = &regenerated[j][i]
+				}
+				issue.add(&records[index], accessor.get(&records[index]), causal)
+			}
+			report.Features = append(report.Features, issue)
+		} else {
+			rawIndex := slices.Index(archive.Features, accessor.name + rawFeatureSuffix)
+			if rawIndex < 0 {
+				continue
+			}
+			issue := CausalityIssue{
+				Name: accessor.name,
+				Examples: []CausalityExample{},
+			}
+			for j, index := range indexes {
+				issue.add(&records[index], accessor.get(&records[index]), accessor.get(&normalized[j]))
+			}
+			report.Quantiles = append(report.Quantiles, issue)
+		}
+	}
+	return report
+}
+
+// Normalizes the raw columns of the sampled records using only the records up to each of them.
+// The stride aligned windows of quantileTransform may rank a record against later ones, which shows up as a mismatch
+func (d *causalityData) normalizeRecords(indexes []int, archive *Archive) []FeatureRecord {
+	if !configuration.QuantileTransform {
+		return make([]FeatureRecord, len(indexes))
+	}
+	rawIndexes := []int{}
+	for _, name := range archive.Features {
+		rawIndexes = append(rawIndexes, slices.Index(archive.Features, name + rawFeatureSuffix))
+	}
+	records := make([]FeatureRecord, len(archive.IntradayRecords))
+	copy(records, archive.IntradayRecords)
+	for i := range records {
+		features := slices.Clone(records[i].Features)
+		for j, rawIndex := range rawIndexes {
+			if rawIndex >= 0 {
+				features[j] = features[rawIndex]
+			}
+		}
+		records[i].Features = features
+	}
+	bufferSize := min(configuration.QuantileBufferSize, len(records))
+	return parallelMap(indexes, func (index int) FeatureRecord {
+		return causalQuantileTransformRecord(bufferSize, index, records)
+	})
+}
+
+// Regenerates the features of the bar starting at the timestamp using only bars up to that timestamp and daily records of previous sessions.
+// The contract traded in the current session is still selected using the daily records of that session.
+func (d *causalityData) regenerateFeatures(
+	fNumber int,
+	timestamp time.Time,
+	intradayRecords intradayRecordsMap,
+	dailyRanges dailyRangeMap,
+) []float64 {
+	date := d.asset.getCalendar().getSessionDate(timestamp)
+	dailyCloses := dailyCloseMap{}
+	for key, close := range d.daily.dailyCloses {
+		if key.date.Before(date) {
+			dailyCloses[key] = close
+		}
+	}
+	dailyOpenInterest := dailyOpenInterestMap{}
+	for key, openInterest := range d.daily.dailyOpenInterest {
+		if key.date.Before(date) {
+			dailyOpenInterest[key] = openInterest
+		}
+	}
+	openIntRecords := []openInterestRecords{}
+	for _, datedRecords := range d.daily.openIntRecords {
+		if datedRecords.date.After(date) {
+			break
+		}
+		openIntRecords = append(openIntRecords, datedRecords)
+	}
+	dailyMap := getDailyRecordMap(fNumber, openIntRecords)
+	rolls := getRolls(openIntRecords, dailyMap)
+	adjustment := newPriceAdjustment(d.asset.getAdjustment(), rolls, dailyCloses, &d.asset)
+	archive := Archive{}
+	processIntradayTimestamp(
+		timestamp,
+		dailyMap,
+		dailyCloses,
+		dailyOpenInterest,
+		intradayRecords,
+		dailyRanges,
+		adjustment,
+		d.location,
+		&d.asset,
+		&archive,
+	)
+	if len(archive.IntradayRecords) == 0 {
+		return nil
+	}
+	return archive.IntradayRecords[0].Features
+}
+
+func (i *CausalityIssue) add(record *FeatureRecord, archiveValue *float64, causal *float64) {
+	i.Checked++
+	if archiveValue == nil && causal == nil {
+		return
+	}
+	if archiveValue != nil && causal != nil {
+		tolerance := causalityTolerance * math.Max(math.Abs(*causal), 1.0)
+		if math.Abs(*archiveValue - *causal) <= tolerance {
+			return
+		}
+	}
+	i.Mismatches++
+	if len(i.Examples) < causalityExampleLimit {
+		example := CausalityExample{
+			Time: getTimeString(record.Timestamp),
+			Archive: archiveValue,
+			Causal: causal,
+		}
+		i.Examples = append(i.Examples, example)
+	}
+}
+
+func printCausalitySummary(reports []CausalityReport) {
+	for _, report := range reports {
+		leaks := []string{}
+		for _, issue := range slices.Concat(report.Features, report.Quantiles) {
+			if issue.Mismatches > 0 {
+				leak := fmt.Sprintf("%s (%d/%d)", issue.Name, issue.Mismatches, issue.Checked)
+				leaks = append(leaks, leak)
+			}
+		}
+		if len(leaks) == 0 {
+			fmt.Printf("[%s] No look-ahead detected in %d samples\n", report.Symbol, report.Samples)
+		} else {
+			fmt.Printf("[%s] Values differ from causal regeneration: %s\n", report.Symbol, strings.Join(leaks, ", "))
+		}
+	}
+}
\ No newline at end of file
@@ -0,0 +1,417 @@
+package sibylla
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+const columnarExtension = "sibc"
+const columnarMagic = "SIBYLLAC"
+const columnarVersion = 1
+const columnarFixedSize = 40
+const columnarAlignment = 8
+const missingTicks = math.MinInt32
+
+// Everything but the intraday records, including the bars, is stored as a Gob encoded block in front of the columns
+type columnarMetadata struct {
+	Header ArchiveHeader
+	Symbol string
+	Timezone string
+	Features []string
+	HoldingTimes []int
+	DailyRecords []DailyRecord
+	Rolls []Roll
+	Bars []BarSeries
+}
+
+type columnarWriter struct {
+	writer *bufio.Writer
+	offset int64
+	path string
+}
+
+// Mapped columnar archive, the columns are views of the file and must not be accessed after close
+type columnarArchive struct {
+	metadata columnarMetadata
+	timestamps []int64
+	features64 [][]float64
+	features32 [][]float32
+	ticks [][4][]int32
+	unmap func ()
+}
+
+type columnarReader struct {
+	data []byte
+	offset int
+	path string
+}
+
+func getColumnarPath(archivePath string) string {
+	return strings.TrimSuffix(archivePath, archiveExtension) + columnarExtension
+}
+
+func isColumnarPath(path string) bool {
+	return strings.HasSuffix(path, "." + columnarExtension)
+}
+
+// Columnar archives are used instead of .gobz archives unless they are older than them
+func getLoadPath(archivePath string) string {
+	columnarPath := getColumnarPath(archivePath)
+	columnarInfo, err := os.Stat(columnarPath)
+	if err != nil {
+		return archivePath
+	}
+	archiveInfo, err := os.Stat(archivePath)
+	if err == nil && archiveInfo.ModTime().After(columnarInfo.ModTime()) {
+		return archivePath
+	}
+	return columnarPath
+}
+
+func ConvertArchives(symbol string) {
+	loadConfiguration()
+	start := time.Now()
+	paths := []string{}
+	for _, asset := range *assets {
+		if symbol != "all" && asset.Symbol != symbol {
+			continue
+		}
+		fRecords := 1
+		if asset.FRecords != nil {
+			fRecords = *asset.FRecords
+		}
+		for fNumber := 1; fNumber <= fRecords; fNumber++ {
+			paths = append(paths, getArchivePath(asset.Symbol, fNumber))
+		}
+	}
+	if len(paths) == 0 {
+		log.Fatalf("Unable to find asset \"%s\"", symbol)
+	}
+	parallelForEach(paths, func (path string) {
+		_, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			fmt.Printf("Archive does not exist, skipping: %s\n", path)
+			return
+		}
+		header := readArchiveHeader(path)
+		if header.Version != archiveVersion {
+			fmt.Printf("Archive has format version %d, regenerate it before converting: %s\n", header.Version, path)
+			return
+		}
+		archive := readArchive(path)
+		columnarPath := getColumnarPath(path)
+		sizeBytes := writeColumnarArchive(columnarPath, &header, &archive)
+		sizeMibibytes := float64(sizeBytes) / 1024.0 / 1024.0
+		fmt.Printf("[%s] Wrote columnar archive to %s (%.1f MiB)\n", archive.Symbol, columnarPath, sizeMibibytes)
+	})
+	delta := time.Since(start)
+	fmt.Printf("Converted archives in %.2f s\n", delta.Seconds())
+}
+
+func writeColumnarArchive(path string, header *ArchiveHeader, archive *Archive) int64 {
+	file, err := os.Create(path)
+	if err != nil {
+		log.Fatalf("Failed to write columnar archive to %s: %v", path, err)
+	}
+	defer file.Close()
+	writer := columnarWriter{
+		writer: bufio.NewWriter(file),
+		path: path,
+	}
+	metadata := columnarMetadata{
+		Header: *header,
+		Symbol: archive.Symbol,
+		Timezone: archive.Timezone,
+		Features: archive.Features,
+		HoldingTimes: archive.HoldingTimes,
+		DailyRecords: archive.DailyRecords,
+		Rolls: archive.Rolls,
+		Bars: archive.Bars,
+	}
+	var metadataBuffer bytes.Buffer
+	err = gob.NewEncoder(&metadataBuffer).Encode(metadata)
+	if err != nil {
+		log.Fatalf("Failed to encode metadata of columnar archive %s: %v", path, err)
+	}
+	floatSize := 8
+	if configuration.ColumnarFloat32 {
+		floatSize = 4
+	}
+	records := archive.IntradayRecords
+	writer.write([]byte(columnarMagic))
+	writer.write(uint32(columnarVersion))
+	writer.write(uint32(floatSize))
+	writer.write(uint64(len(records)))
+	writer.write(uint32(len(archive.Features)))
+	writer.write(uint32(len(archive.HoldingTimes)))
+	writer.write(uint64(metadataBuffer.Len()))
+	writer.write(metadataBuffer.Bytes())
+	writer.align()
+	for _, record := range records {
+		writer.write(record.Timestamp.UnixNano())
+	}
+	for i := range archive.Features {
+		for _, record := range records {
+			value := record.Features[i]
+			if floatSize == 4 {
+				writer.write(float32(value))
+			} else {
+				writer.write(value)
+			}
+		}
+		writer.align()
+	}
+	ticksColumns := []func (ReturnsRecord) int{
+		func (r ReturnsRecord) int { return r.High },
+		func (r ReturnsRecord) int { return r.Low },
+		func (r ReturnsRecord) int { return r.Close1 },
+		func (r ReturnsRecord) int { return r.Close2 },
+	}
+	for i := range archive.HoldingTimes {
+		for _, getTicks := range ticksColumns {
+			for _, record := range records {
+				ticks := int32(missingTicks)
+				if record.Returns[i].Valid {
+					ticks = int32(getTicks(record.Returns[i]))
+				}
+				writer.write(ticks)
+			}
+			writer.align()
+		}
+	}
+	err = writer.writer.Flush()
+	if err != nil {
+		log.Fatalf("Failed to write columnar archive to %s: %v", path, err)
+	}
+	return writer.offset
+}
+
+func (w *columnarWriter) write(data any) {
+	err := binary.Write(w.writer, binary.LittleEndian, data)
+	if err != nil {
+		log.Fatalf("Failed to write columnar archive to %s: %v", w.path, err)
+	}
+	w.offset += int64(binary.Size(data))
+}
+
+func (w *columnarWriter) align() {
+	padding := (columnarAlignment - w.offset % columnarAlignment) % columnarAlignment
+	w.write(make([]byte, padding))
+}
+
+func readColumnarHeader(path string) ArchiveHeader {
+	data, unmap := mapFile(path)
+	defer unmap()
+	reader := columnarReader{
+		data: data,
+		path: path,
+	}
+	_, _, _, _, metadata := reader.readPreamble()
+	return metadata.Header
+}
+
+func readColumnarArchive(path string) Archive {
+	archive := openColumnarArchive(path)
+	defer archive.close()
+	return archive.getArchive(0, len(archive.timestamps))
+}
+
+// Only the intraday records within the date range are copied out of the mapping
+func readColumnarArchiveRange(path string, dateMin SerializableDate, dateMax SerializableDate) Archive {
+	archive := openColumnarArchive(path)
+	defer archive.close()
+	return archive.getArchive(archive.search(dateMin.Time), archive.search(dateMax.Time))
+}
+
+func openColumnarArchive(path string) *columnarArchive {
+	if binary.NativeEndian.Uint16([]byte{1, 0}) != 1 {
+		log.Fatalf("Unable to map columnar archive %s on a big-endian machine", path)
+	}
+	data, unmap := mapFile(path)
+	if len(data) > 0 && uintptr(unsafe.Pointer(&data[0])) % columnarAlignment != 0 {
+		unmap()
+		log.Fatalf("Mapping of columnar archive %s is not aligned", path)
+	}
+	reader := columnarReader{
+		data: data,
+		path: path,
+	}
+	floatSize, recordCount, featureCount, holdingTimeCount, metadata := reader.readPreamble()
+	if metadata.Header.Version != archiveVersion {
+		unmap()
+		log.Fatalf("Archive %s has format version %d but version %d is required, regenerate it", path, metadata.Header.Version, archiveVersion)
+	}
+	archive := columnarArchive{
+		metadata: metadata,
+		timestamps: getColumnView[int64](&reader, recordCount),
+		unmap: unmap,
+	}
+	for range featureCount {
+		if floatSize == 4 {
+			archive.features32 = append(archive.features32, getColumnView[float32](&reader, recordCount))
+		} else {
+			archive.features64 = append(archive.features64, getColumnView[float64](&reader, recordCount))
+		}
+	}
+	for range holdingTimeCount {
+		var ticks [4][]int32
+		for j := range ticks {
+			ticks[j] = getColumnView[int32](&reader, recordCount)
+		}
+		archive.ticks = append(archive.ticks, ticks)
+	}
+	return &archive
+}
+
+func (a *columnarArchive) close() {
+	a.timestamps = nil
+	a.features32 = nil
+	a.features64 = nil
+	a.ticks = nil
+	a.unmap()
+}
+
+// Index of the first intraday record at or after the timestamp
+func (a *columnarArchive) search(timestamp time.Time) int {
+	// Comparing times rather than nanoseconds, since dates past 2262 overflow UnixNano
+	return sort.Search(len(a.timestamps), func (i int) bool {
+		return !time.Unix(0, a.timestamps[i]).Before(timestamp)
+	})
+}
+
+// Copies the intraday records in [from, to) out of the mapping, one column at a time
+func (a *columnarArchive) getArchive(from int, to int) Archive {
+	recordCount := to - from
+	featureCount := len(a.metadata.Features)
+	holdingTimeCount := len(a.metadata.HoldingTimes)
+	features := make([]float64, recordCount * featureCount)
+	returns := make([]ReturnsRecord, recordCount * holdingTimeCount)
+	records := make([]FeatureRecord, recordCount)
+	for i := range records {
+		records[i].Timestamp = time.Unix(0, a.timestamps[from + i]).UTC()
+		records[i].Features = features[i * featureCount:(i + 1) * featureCount:(i + 1) * featureCount]
+		records[i].Returns = returns[i * holdingTimeCount:(i + 1) * holdingTimeCount:(i + 1) * holdingTimeCount]
+	}
+	for j, column := range a.features32 {
+		for i, value := range column[from:to] {
+			records[i].Features[j] = float64(value)
+		}
+	}
+	for j, column := range a.features64 {
+		for i, value := range column[from:to] {
+			records[i].Features[j] = value
+		}
+	}
+	ticksColumns := []func (*ReturnsRecord) *int{
+		func (r *ReturnsRecord) *int { return &r.High },
+		func (r *ReturnsRecord) *int { return &r.Low },
+		func (r *ReturnsRecord) *int { return &r.Close1 },
+		func (r *ReturnsRecord) *int { return &r.Close2 },
+	}
+	for j, ticks := range a.ticks {
+		for k, getTicks := range ticksColumns {
+			for i, value := range ticks[k][from:to] {
+				returnsRecord := &records[i].Returns[j]
+				if value != missingTicks {
+					returnsRecord.Valid = true
+					*getTicks(returnsRecord) = int(value)
+				}
+			}
+		}
+	}
+	metadata := a.metadata
+	return Archive{
+		Symbol: metadata.Symbol,
+		Timezone: metadata.Timezone,
+		Features: metadata.Features,
+		HoldingTimes: metadata.HoldingTimes,
+		DailyRecords: metadata.DailyRecords,
+		IntradayRecords: records,
+		Rolls: metadata.Rolls,
+		Bars: metadata.Bars,
+	}
+}
+
+func (r *columnarReader) readPreamble() (int, int, int, int, columnarMetadata) {
+	magic := string(r.read(len(columnarMagic)))
+	if magic != columnarMagic {
+		log.Fatalf("Invalid columnar archive %s", r.path)
+	}
+	version := r.readUint32()
+	if version != columnarVersion {
+		log.Fatalf("Columnar archive %s has version %d but version %d is required, convert it again", r.path, version, columnarVersion)
+	}
+	floatSize := int(r.readUint32())
+	if floatSize != 4 && floatSize != 8 {
+		log.Fatalf("Invalid float size %d in columnar archive %s", floatSize, r.path)
+	}
+	recordCount := int(r.readUint64())
+	featureCount := int(r.readUint32())
+	holdingTimeCount := int(r.readUint32())
+	metadataLength := int(r.readUint64())
+	var metadata columnarMetadata
+	decoder := gob.NewDecoder(bytes.NewReader(r.read(metadataLength)))
+	err := decoder.Decode(&metadata)
+	if err != nil {
+		log.Fatalf("Failed to decode metadata of columnar archive %s: %v", r.path, err)
+	}
+	if len(metadata.Features) != featureCount || len(metadata.HoldingTimes) != holdingTimeCount {
+		log.Fatalf("Inconsistent column counts in columnar archive %s", r.path)
+	}
+	// Mirrors the padding inserted by the writer after the metadata and after each column
+	expectedSize := getAlignedOffset(columnarFixedSize + metadataLength) +
+		getAlignedOffset(recordCount * 8) +
+		featureCount * getAlignedOffset(recordCount * floatSize) +
+		holdingTimeCount * 4 * getAlignedOffset(recordCount * 4)
+	if len(r.data) < expectedSize {
+		log.Fatalf("Columnar archive %s is truncated", r.path)
+	}
+	r.align()
+	return floatSize, recordCount, featureCount, holdingTimeCount, metadata
+}
+
+func (r *columnarReader) read(length int) []byte {
+	if r.offset + length > len(r.data) {
+		log.Fatalf("Columnar archive %s is truncated", r.path)
+	}
+	data := r.data[r.offset:r.offset + length]
+	r.offset += length
+	return data
+}
+
+func (r *columnarReader) readUint32() uint32 {
+	return binary.LittleEndian.Uint32(r.read(4))
+}
+
+func (r *columnarReader) readUint64() uint64 {
+	return binary.LittleEndian.Uint64(r.read(8))
+}
+
+func (r *columnarReader) align() {
+	padding := (columnarAlignment - r.offset % columnarAlignment) % columnarAlignment
+	r.read(padding)
+}
+// Column views rely on the little-endian layout and the alignment of the columns within the mapping
+func getColumnView[T int32 | int64 | float32 | float64](r *columnarReader, count int) []T {
+	var value T
+	data := r.read(count * int(unsafe.Sizeof(value)))
+	r.align()
+	if count == 0 {
+		return nil
+	}
+	return unsafe.Slice((*T)(unsafe.Pointer(&data[0])), count)
+}
+
+func getAlignedOffset(offset int) int {
+	return (offset + columnarAlignment - 1) / columnarAlignment * columnarAlignment
+}
\ No newline at end of file
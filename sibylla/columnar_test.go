@@ -0,0 +1,116 @@
+package sibylla
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func getColumnarTestArchive(records int) Archive {
+	start := time.Date(2024, time.January, 2, 14, 0, 0, 0, time.UTC)
+	archive := Archive{
+		Symbol: "ES",
+		Timezone: "America/Chicago",
+		Features: []string{"momentum1D", "momentum1DRaw", "volatility"},
+		HoldingTimes: []int{4, 24},
+		DailyRecords: []DailyRecord{
+			{Date: getDateFromTime(start), Close: 4750.25},
+		},
+	}
+	for i := range records {
+		record := FeatureRecord{
+			Timestamp: start.Add(time.Duration(i) * time.Hour),
+			// Values that are not representable as float32 and a missing value
+			Features: []float64{float64(i) + 0.1, math.NaN(), -1.0 / 3.0},
+			Returns: []ReturnsRecord{
+				{Valid: true, High: i, Low: -i, Close1: 2, Close2: -3},
+				{},
+			},
+		}
+		archive.IntradayRecords = append(archive.IntradayRecords, record)
+	}
+	return archive
+}
+
+func TestColumnarArchive(t *testing.T) {
+	tests := []struct {
+		name string
+		float32 bool
+		records int
+	}{
+		{"float64", false, 5},
+		{"float32", true, 5},
+		{"float32 padding", true, 3},
+		{"empty", false, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func (t *testing.T) {
+			configuration = &Configuration{
+				ColumnarFloat32: test.float32,
+			}
+			archive := getColumnarTestArchive(test.records)
+			header := ArchiveHeader{
+				Version: archiveVersion,
+			}
+			path := filepath.Join(t.TempDir(), "ES.F1.sibc")
+			size := writeColumnarArchive(path, &header, &archive)
+			fileInfo, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fileInfo.Size() != size {
+				t.Errorf("Reported size %d does not match file size %d", size, fileInfo.Size())
+			}
+			if readColumnarHeader(path).Version != archiveVersion {
+				t.Errorf("Header was not preserved")
+			}
+			output := readColumnarArchive(path)
+			if output.Symbol != archive.Symbol || output.Timezone != archive.Timezone {
+				t.Errorf("Metadata was not preserved")
+			}
+			if !slices.Equal(output.Features, archive.Features) || !slices.Equal(output.HoldingTimes, archive.HoldingTimes) {
+				t.Errorf("Columns were not preserved")
+			}
+			if !slices.Equal(output.DailyRecords, archive.DailyRecords) {
+				t.Errorf("Daily records were not preserved")
+			}
+			if len(output.IntradayRecords) != len(archive.IntradayRecords) {
+				t.Fatalf("Read %d intraday records, expected %d", len(output.IntradayRecords), len(archive.IntradayRecords))
+			}
+			for i, record := range output.IntradayRecords {
+				expected := archive.IntradayRecords[i]
+				if !record.Timestamp.Equal(expected.Timestamp) {
+					t.Errorf("Record %d: timestamp = %s, expected = %s", i, getTimeString(record.Timestamp), getTimeString(expected.Timestamp))
+				}
+				for j, value := range record.Features {
+					expectedValue := expected.Features[j]
+					if test.float32 {
+						expectedValue = float64(float32(expectedValue))
+					}
+					if value != expectedValue && !(math.IsNaN(value) && math.IsNaN(expectedValue)) {
+						t.Errorf("Record %d: %s = %f, expected = %f", i, archive.Features[j], value, expectedValue)
+					}
+				}
+				if !slices.Equal(record.Returns, expected.Returns) {
+					t.Errorf("Record %d: returns = %v, expected = %v", i, record.Returns, expected.Returns)
+				}
+			}
+			dateMin := SerializableDate{
+				Time: time.Date(2024, time.January, 2, 15, 0, 0, 0, time.UTC),
+			}
+			dateMax := SerializableDate{
+				Time: time.Date(9999, time.January, 1, 0, 0, 0, 0, time.UTC),
+			}
+			rangeOutput := readColumnarArchiveRange(path, dateMin, dateMax)
+			expectedRecords := max(test.records - 1, 0)
+			if len(rangeOutput.IntradayRecords) != expectedRecords {
+				t.Errorf("Read %d intraday records within range, expected %d", len(rangeOutput.IntradayRecords), expectedRecords)
+			} else if expectedRecords > 0 && !rangeOutput.IntradayRecords[0].Timestamp.Equal(dateMin.Time) {
+				t.Errorf("Range starts at %s instead of %s", getTimeString(rangeOutput.IntradayRecords[0].Timestamp), getTimeString(dateMin.Time))
+			}
+		})
+	}
+}
\ No newline at end of file
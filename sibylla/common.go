@@ -160,12 +160,24 @@ func clearDirectory(path string) {
 }
 
 func readCsv(path string, columns []string, callback func([]string)) {
+	readCsvDelimiter(path, ',', columns, callback)
+}
+
+func readCsvDelimiter(path string, delimiter rune, columns []string, callback func([]string)) {
+	readCsvOffset(path, delimiter, 0, columns, func (values []string, _ int64) {
+		callback(values)
+	})
+}
+
+// Starts reading rows at the byte offset of a row, the callback also receives the byte offset of each row
+func readCsvOffset(path string, delimiter rune, offset int64, columns []string, callback func([]string, int64)) {
 	file, err := os.Open(path)
 	if err != nil {
 		log.Fatalf("Failed to read CSV file (%s): %v", path, err)
 	}
 	defer file.Close()
 	reader := csv.NewReader(file)
+	reader.Comma = delimiter
 	headers, err := reader.Read()
 	if err != nil {
 		log.Fatal("Failed to read CSV headers", err)
@@ -182,8 +194,20 @@ func readCsv(path string, columns []string, callback func([]string)) {
 		}
 		indexMap = append(indexMap, index)
 	}
+	var base int64
+	if offset > 0 {
+		_, err = file.Seek(offset, io.SeekStart)
+		if err != nil {
+			log.Fatalf("Failed to seek to offset %d in CSV file (%s): %v", offset, path, err)
+		}
+		reader = csv.NewReader(file)
+		reader.Comma = delimiter
+		reader.FieldsPerRecord = len(headers)
+		base = offset
+	}
 	callbackColumns := make([]string, len(columns))
 	for {
+		rowOffset := base + reader.InputOffset()
 		record, err := reader.Read()
 		if err == io.EOF {
 			break
@@ -193,7 +217,7 @@ func readCsv(path string, columns []string, callback func([]string)) {
 		for destination, source := range indexMap {
 			callbackColumns[destination] = record[source]
 		}
-		callback(callbackColumns)
+		callback(callbackColumns, rowOffset)
 	}
 }
 
@@ -256,13 +280,20 @@ func parseFloat(floatString string) float64 {
 
 func formatMoney(amount int64) string {
 	amountString := fmt.Sprintf("%d", amount)
-	output := "$"
+	output := ""
+	currency := getAccountCurrency()
+	if currency == currencyUSD {
+		output = "$"
+	}
 	for i, character := range amountString {
 		if i > 0 && (len(amountString) - i) % 3 == 0 {
 			output += ","
 		}
 		output += string(character)
 	}
+	if currency != currencyUSD {
+		output += " " + currency
+	}
 	return output
 }
 
@@ -2,15 +2,19 @@ package sibylla
 
 import (
 	"log"
+	"slices"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Configuration struct {
 	BarchartPath string `yaml:"barchartPath"`
+	BarchartTimezone string `yaml:"barchartTimezone"`
 	GobPath string `yaml:"gobPath"`
 	CutoffDate SerializableDate `yaml:"cutoffDate"`
 	OverwriteArchives bool `yaml:"overwriteArchives"`
+	ColumnarArchives bool `yaml:"columnarArchives"`
+	ColumnarFloat32 bool `yaml:"columnarFloat32"`
 	QuantileTransform bool `yaml:"quantileTransform"`
 	QuantileBufferSize int `yaml:"quantileBufferSize"`
 	QuantileStride int `yaml:"quantileStride"`
@@ -21,11 +25,26 @@ type Configuration struct {
 	IconPath string `yaml:"iconPath"`
 	ProfilerAddress *string `yaml:"profilerAddress"`
 	RiskFreeRatePath string `yaml:"riskFreeRatePath"`
+	RiskFreeRates []RiskFreeRateConfiguration `yaml:"riskFreeRates"`
+	RiskFreeRateFill string `yaml:"riskFreeRateFill"`
+	CalendarPath string `yaml:"calendarPath"`
+	AuditPath string `yaml:"auditPath"`
+	AuditSigma float64 `yaml:"auditSigma"`
+	ExportPath string `yaml:"exportPath"`
+	CausalityPath string `yaml:"causalityPath"`
+	DiffPath string `yaml:"diffPath"`
+	CausalitySamples int `yaml:"causalitySamples"`
+	EventsPath string `yaml:"eventsPath"`
+	AccountCurrency string `yaml:"accountCurrency"`
+	CurrencyMaxGap int `yaml:"currencyMaxGap"`
+	HoldingTimes []int `yaml:"holdingTimes"`
 }
 
 const configurationPath = "configuration/configuration.yaml"
 const assetsPath = "configuration/assets.yaml"
 
+var defaultHoldingTimes = []int{4, 8, 16, 24, 48, 72}
+
 var loadedConfiguration bool
 var configuration *Configuration
 var assets *[]Asset
@@ -36,6 +55,12 @@ func loadConfiguration() {
 	}
 	loadBaseConfiguration()
 	loadAssets()
+	loadFeatureCatalog()
+	loadCalendars()
+	loadEvents()
+	if hasMixedCurrencySynthetics() {
+		loadCurrencies()
+	}
 	loadRiskFreeRate()
 	loadedConfiguration = true
 }
@@ -50,6 +75,32 @@ func loadBaseConfiguration() {
 	if err != nil {
 		log.Fatal("Failed to unmarshal YAML:", err)
 	}
+	for i, holdingTime := range configuration.HoldingTimes {
+		if holdingTime <= 0 || slices.Contains(configuration.HoldingTimes[:i], holdingTime) {
+			log.Fatalf("Invalid holding time in configuration: %d", holdingTime)
+		}
+	}
+}
+
+func getHoldingTimes() []int {
+	if len(configuration.HoldingTimes) > 0 {
+		return configuration.HoldingTimes
+	}
+	return defaultHoldingTimes
+}
+
+// Buy and hold uses daily returns if available, otherwise the longest holding time configured
+func getBuyAndHoldHoldingTime() int {
+	holdingTimes := getHoldingTimes()
+	if slices.Contains(holdingTimes, hoursPerDay) {
+		return hoursPerDay
+	}
+	return slices.Max(holdingTimes)
+}
+
+func getMaxReturnsDays() int {
+	maxHoldingTime := slices.Max(getHoldingTimes())
+	return (maxHoldingTime + hoursPerDay - 1) / hoursPerDay
 }
 
 func loadAssets() {
@@ -62,4 +113,5 @@ func loadAssets() {
 	if err != nil {
 		log.Fatal("Failed to unmarshal YAML:", err)
 	}
+	resolveSyntheticAssets()
 }
\ No newline at end of file
@@ -0,0 +1,250 @@
+package sibylla
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"time"
+)
+
+const crossSectionalMinAssets = 2
+
+type crossSectionalArchive struct {
+	asset Asset
+	path string
+	header ArchiveHeader
+	archive Archive
+	modified bool
+}
+
+func (d *FeatureDefinition) isCrossSectional() bool {
+	return d.Type == featureCrossSectionalRank || d.Type == featureCrossSectionalZScore
+}
+
+func validateCrossSectional(definition *FeatureDefinition) error {
+	if definition.Feature == "" {
+		return fmt.Errorf("%s requires a feature", definition.Type)
+	}
+	if definition.Days > 0 || definition.Hours > 0 || definition.LagDays > 0 || definition.Window > 0 {
+		return fmt.Errorf("%s does not support lookbacks", definition.Type)
+	}
+	if definition.Normalization != "" && definition.Normalization != normalizationRaw {
+		return fmt.Errorf("%s values cannot be normalized", definition.Type)
+	}
+	return nil
+}
+
+// Cross-sectional values are filled in by updateCrossSectionalFeatures once the archives of all assets have been generated
+func getCrossSectionalPlaceholder(definition *FeatureDefinition, context *featureContext) *float64 {
+	return nil
+}
+
+func validateCrossSectionalSources() {
+	for _, definition := range *featureCatalog {
+		if !definition.isCrossSectional() {
+			continue
+		}
+		source, exists := find(*featureCatalog, func (d FeatureDefinition) bool {
+			return d.Name == definition.Feature
+		})
+		if !exists || source.isCrossSectional() {
+			log.Fatalf("Invalid source feature \"%s\" in cross-sectional feature \"%s\"", definition.Feature, definition.Name)
+		}
+		if definition.Group != "" && !slices.ContainsFunc(*assets, func (a Asset) bool {
+			return slices.Contains(a.Groups, definition.Group)
+		}) {
+			log.Fatalf("Unknown asset group \"%s\" in cross-sectional feature \"%s\"", definition.Group, definition.Name)
+		}
+	}
+}
+
+// Features without an explicit group rank assets against the first group they are a member of
+func (a *Asset) getFeatureGroup(definition *FeatureDefinition) string {
+	if definition.Group != "" {
+		if slices.Contains(a.Groups, definition.Group) {
+			return definition.Group
+		}
+		return ""
+	}
+	if len(a.Groups) > 0 {
+		return a.Groups[0]
+	}
+	return ""
+}
+
+// Recomputes cross-sectional features in all archives of groups containing one of the generated assets, nil for all assets
+func updateCrossSectionalFeatures(generated []string) {
+	definitions := []*FeatureDefinition{}
+	for i := range *featureCatalog {
+		definition := &(*featureCatalog)[i]
+		if definition.isCrossSectional() {
+			definitions = append(definitions, definition)
+		}
+	}
+	if len(definitions) == 0 {
+		return
+	}
+	start := time.Now()
+	groupMap := map[string]struct{}{}
+	for _, asset := range *assets {
+		if generated == nil || slices.Contains(generated, asset.Symbol) {
+			for _, group := range asset.Groups {
+				groupMap[group] = struct{}{}
+			}
+		}
+	}
+	groups := []string{}
+	for group := range groupMap {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	members := []Asset{}
+	fLimit := 1
+	for _, asset := range *assets {
+		if slices.ContainsFunc(asset.Groups, func (group string) bool {
+			return slices.Contains(groups, group)
+		}) {
+			members = append(members, asset)
+			if asset.FRecords != nil {
+				fLimit = max(fLimit, *asset.FRecords)
+			}
+		}
+	}
+	names := getFeatureNames()
+	for fNumber := 1; fNumber <= fLimit; fNumber++ {
+		archives := loadCrossSectionalArchives(members, fNumber, names)
+		for _, definition := range definitions {
+			sourceIndex := slices.Index(names, definition.Feature + rawFeatureSuffix)
+			if sourceIndex < 0 {
+				sourceIndex = slices.Index(names, definition.Feature)
+			}
+			targetIndex := slices.Index(names, definition.Name)
+			for _, group := range groups {
+				population := []*crossSectionalArchive{}
+				targets := []*crossSectionalArchive{}
+				for _, archive := range archives {
+					if slices.Contains(archive.asset.Groups, group) {
+						population = append(population, archive)
+						if archive.asset.getFeatureGroup(definition) == group {
+							targets = append(targets, archive)
+						}
+					}
+				}
+				if len(targets) > 0 {
+					setCrossSectionalValues(definition, sourceIndex, targetIndex, population, targets)
+				}
+			}
+		}
+		parallelForEach(archives, func (archive *crossSectionalArchive) {
+			if !archive.modified {
+				return
+			}
+			writeArchive(archive.path, &archive.header, &archive.archive)
+			if configuration.ColumnarArchives {
+				writeColumnarArchive(getColumnarPath(archive.path), &archive.header, &archive.archive)
+			}
+			fmt.Printf("[%s] Updated cross-sectional features in %s\n", archive.asset.Symbol, archive.path)
+		})
+	}
+	delta := time.Since(start)
+	fmt.Printf("Updated cross-sectional features in %.2f s\n", delta.Seconds())
+}
+
+func loadCrossSectionalArchives(members []Asset, fNumber int, names []string) []*crossSectionalArchive {
+	paths := []crossSectionalArchive{}
+	for _, asset := range members {
+		fRecords := 1
+		if asset.FRecords != nil {
+			fRecords = *asset.FRecords
+		}
+		if fNumber > fRecords {
+			continue
+		}
+		path := getArchivePath(asset.Symbol, fNumber)
+		_, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		archive := crossSectionalArchive{
+			asset: asset,
+			path: path,
+		}
+		paths = append(paths, archive)
+	}
+	archives := parallelMap(paths, func (archive crossSectionalArchive) *crossSectionalArchive {
+		archive.header = readArchiveHeader(archive.path)
+		if archive.header.Version != archiveVersion {
+			return nil
+		}
+		archive.archive = readArchive(archive.path)
+		if !slices.Equal(archive.archive.Features, names) {
+			fmt.Printf("[%s] Feature catalog changed, excluding archive from cross-sectional features: %s\n", archive.asset.Symbol, archive.path)
+			return nil
+		}
+		return &archive
+	})
+	return slices.DeleteFunc(archives, func (archive *crossSectionalArchive) bool {
+		return archive == nil
+	})
+}
+
+func setCrossSectionalValues(
+	definition *FeatureDefinition,
+	sourceIndex int,
+	targetIndex int,
+	population []*crossSectionalArchive,
+	targets []*crossSectionalArchive,
+) {
+	values := map[int64][]float64{}
+	for _, member := range population {
+		for _, record := range member.archive.IntradayRecords {
+			value := record.Features[sourceIndex]
+			if !math.IsNaN(value) {
+				key := record.Timestamp.Unix()
+				values[key] = append(values[key], value)
+			}
+		}
+	}
+	for _, target := range targets {
+		for i := range target.archive.IntradayRecords {
+			record := &target.archive.IntradayRecords[i]
+			value := record.Features[sourceIndex]
+			sample := values[record.Timestamp.Unix()]
+			if math.IsNaN(value) || len(sample) < crossSectionalMinAssets {
+				record.Features[targetIndex] = math.NaN()
+			} else if definition.Type == featureCrossSectionalZScore {
+				record.Features[targetIndex] = getSampleZScore(value, sample)
+			} else {
+				less := 0
+				for _, x := range sample {
+					if x < value {
+						less++
+					}
+				}
+				record.Features[targetIndex] = float64(less) / float64(len(sample) - 1)
+			}
+		}
+		target.modified = true
+	}
+}
+
+func getSampleZScore(value float64, sample []float64) float64 {
+	sum := 0.0
+	for _, x := range sample {
+		sum += x
+	}
+	mean := sum / float64(len(sample))
+	deltaSum := 0.0
+	for _, x := range sample {
+		delta := x - mean
+		deltaSum += delta * delta
+	}
+	stdDev := math.Sqrt(deltaSum / float64(len(sample) - 1))
+	if stdDev == 0 {
+		return 0.0
+	}
+	return (value - mean) / stdDev
+}
\ No newline at end of file
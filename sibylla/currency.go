@@ -3,70 +3,154 @@ package sibylla
 import (
 	"fmt"
 	"log"
+	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"time"
 )
 
+// All exchange rates are loaded relative to this currency, other cross rates are triangulated
 const currencyUSD = "USD"
-const currencyEUR = "EUR"
-const currencyJPY = "JPY"
 
-type currencyMap struct {
+// Maximum number of hours an exchange rate may be carried forward
+const defaultCurrencyMaxGap = 100
+
+// Hourly exchange rates expressed as the value of one unit of the currency in USD, sorted by timestamp
+type currencySeries struct {
 	symbol string
-	records map[time.Time]float64
+	timestamps []time.Time
+	rates []float64
 }
 
-var currencies *[]currencyMap
+var currencies map[string]*currencySeries
 
 func loadCurrencies() {
-	symbols := []string{
-		currencyEUR,
-		currencyJPY,
+	if currencies != nil {
+		return
+	}
+	symbols := []string{}
+	addSymbol := func (symbol string) {
+		if symbol != "" && symbol != currencyUSD && !slices.Contains(symbols, symbol) {
+			symbols = append(symbols, symbol)
+		}
 	}
-	currencyMaps := []currencyMap{}
+	for _, asset := range *assets {
+		addSymbol(asset.Currency)
+	}
+	addSymbol(getAccountCurrency())
+	seriesMap := map[string]*currencySeries{}
 	for _, symbol := range symbols {
-		currencyMap := loadCurrency(symbol)
-		currencyMaps = append(currencyMaps, currencyMap)
+		seriesMap[symbol] = loadCurrency(symbol)
 	}
-	currencies = &currencyMaps
+	currencies = seriesMap
 }
 
-func loadCurrency(symbol string) currencyMap {
-	filename := fmt.Sprintf("^%s%s.H1.csv", symbol, currencyUSD)
-	path := filepath.Join(configuration.BarchartPath, filename)
+// Barchart quotes most currencies against USD, some of them are only available as USD/XXX and are inverted
+func loadCurrency(symbol string) *currencySeries {
+	path := getCurrencyPath(symbol, currencyUSD)
+	inverted := false
+	_, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		path = getCurrencyPath(currencyUSD, symbol)
+		inverted = true
+	}
 	columns := []string{"time", "close"}
-	records := map[time.Time]float64{}
+	series := currencySeries{
+		symbol: symbol,
+	}
+	location := getLocation(getBarchartTimezone())
 	callback := func(values []string) {
-		timestamp := getTime(values[0])
+		timestamp := getUTCTime(getTime(values[0]), location)
 		close := parseFloat(values[1])
-		records[timestamp] = close
+		if inverted {
+			if close <= 0 {
+				log.Fatalf("Invalid exchange rate for %s at %s in %s", symbol, getTimeString(timestamp), path)
+			}
+			close = 1.0 / close
+		}
+		series.timestamps = append(series.timestamps, timestamp)
+		series.rates = append(series.rates, close)
 	}
 	readCsv(path, columns, callback)
-	currencyMap := currencyMap{
-		symbol: symbol,
-		records: records,
+	if len(series.timestamps) == 0 {
+		log.Fatalf("No exchange rates for %s in %s", symbol, path)
 	}
-	return currencyMap
+	if !sort.SliceIsSorted(series.timestamps, func (i, j int) bool {
+		return series.timestamps[i].Before(series.timestamps[j])
+	}) {
+		sort.Sort(&series)
+	}
+	return &series
+}
+
+func getCurrencyPath(base, quote string) string {
+	filename := fmt.Sprintf("^%s%s.H1.csv", base, quote)
+	return filepath.Join(configuration.BarchartPath, filename)
 }
 
-// Warning: this function currently ignores timezones and spreads
+func getAccountCurrency() string {
+	if configuration.AccountCurrency == "" {
+		return currencyUSD
+	}
+	return configuration.AccountCurrency
+}
+
+// Converts an amount into the account currency, using the last exchange rate available at the time
 func convertCurrency(timestamp time.Time, amount float64, symbol string) float64 {
-	if symbol == currencyUSD {
+	return convertCurrencies(timestamp, amount, symbol, getAccountCurrency())
+}
+
+func convertCurrencies(timestamp time.Time, amount float64, source, destination string) float64 {
+	if source == destination {
 		return amount
 	}
-	for _, currencyMap := range *currencies {
-		if currencyMap.symbol == symbol {
-			for i := 0; i < 100; i++ {
-				close, exists := currencyMap.records[timestamp]
-				if exists {
-					converted := close * amount
-					return converted
-				}
-				timestamp = timestamp.Add(- time.Hour)
-			}
-			log.Fatalf("Failed to find a matching record for timestamp %s for currency %s", getTimeString(timestamp), symbol)
-		}
+	return amount * getUSDRate(timestamp, source) / getUSDRate(timestamp, destination)
+}
+
+func getUSDRate(timestamp time.Time, symbol string) float64 {
+	if symbol == currencyUSD {
+		return 1.0
 	}
-	log.Fatalf("Failed to find currency %s", symbol)
-	return 0.0
+	series, exists := currencies[symbol]
+	if !exists {
+		log.Fatalf("Failed to find currency %s", symbol)
+	}
+	return series.getRate(timestamp)
+}
+
+func getCurrencyMaxGap() time.Duration {
+	maxGap := defaultCurrencyMaxGap
+	if configuration.CurrencyMaxGap > 0 {
+		maxGap = configuration.CurrencyMaxGap
+	}
+	return time.Duration(maxGap) * time.Hour
+}
+
+// Uses the last exchange rate available at the time, which must not be older than currencyMaxGap
+func (s *currencySeries) getRate(timestamp time.Time) float64 {
+	index := sort.Search(len(s.timestamps), func (i int) bool {
+		return s.timestamps[i].After(timestamp)
+	})
+	if index == 0 {
+		log.Fatalf("No exchange rate for %s available at %s, the series starts at %s", s.symbol, getTimeString(timestamp), getTimeString(s.timestamps[0]))
+	}
+	rateTimestamp := s.timestamps[index - 1]
+	if timestamp.Sub(rateTimestamp) > getCurrencyMaxGap() {
+		log.Fatalf("Last exchange rate for %s at %s is too old for %s, check currencyMaxGap", s.symbol, getTimeString(rateTimestamp), getTimeString(timestamp))
+	}
+	return s.rates[index - 1]
+}
+
+func (s *currencySeries) Len() int {
+	return len(s.timestamps)
+}
+
+func (s *currencySeries) Less(i, j int) bool {
+	return s.timestamps[i].Before(s.timestamps[j])
+}
+
+func (s *currencySeries) Swap(i, j int) {
+	s.timestamps[i], s.timestamps[j] = s.timestamps[j], s.timestamps[i]
+	s.rates[i], s.rates[j] = s.rates[j], s.rates[i]
 }
\ No newline at end of file
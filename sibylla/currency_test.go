@@ -0,0 +1,61 @@
+package sibylla
+
+import (
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+func writeCurrencyTestFile(t *testing.T, base, quote, content string) {
+	path := getCurrencyPath(base, quote)
+	err := os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConvertCurrencies(t *testing.T) {
+	configuration = &Configuration{
+		BarchartPath: t.TempDir(),
+		BarchartTimezone: "UTC",
+	}
+	writeCurrencyTestFile(t, "EUR", currencyUSD, "time,close\n2024-01-02 10:00,1.10\n2024-01-02 11:00,1.12\n")
+	// Only available as USD/JPY, rows out of order
+	writeCurrencyTestFile(t, currencyUSD, "JPY", "time,close\n2024-01-02 11:00,160\n2024-01-02 10:00,150\n")
+	currencies = map[string]*currencySeries{
+		"EUR": loadCurrency("EUR"),
+		"JPY": loadCurrency("JPY"),
+	}
+	defer func () {
+		currencies = nil
+	}()
+	getTimestamp := func (hour, minute int) time.Time {
+		return time.Date(2024, time.January, 2, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name string
+		timestamp time.Time
+		amount float64
+		source string
+		destination string
+		expected float64
+	}{
+		{"same currency", getTimestamp(10, 30), 5, "EUR", "EUR", 5},
+		{"direct", getTimestamp(10, 30), 100, "EUR", currencyUSD, 110},
+		{"direct reverse", getTimestamp(10, 30), 110, currencyUSD, "EUR", 100},
+		{"inverted", getTimestamp(10, 30), 100, currencyUSD, "JPY", 15000},
+		{"inverted reverse", getTimestamp(11, 0), 16000, "JPY", currencyUSD, 100},
+		{"triangulated", getTimestamp(11, 0), 1, "EUR", "JPY", 1.12 * 160},
+		{"triangulated reverse", getTimestamp(11, 30), 1000, "JPY", "EUR", 1000 / 160.0 / 1.12},
+		{"within maximum gap", time.Date(2024, time.January, 6, 11, 0, 0, 0, time.UTC), 100, "EUR", currencyUSD, 112},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func (t *testing.T) {
+			converted := convertCurrencies(test.timestamp, test.amount, test.source, test.destination)
+			if math.Abs(converted - test.expected) > 1e-9 * math.Max(math.Abs(test.expected), 1.0) {
+				t.Errorf("Converted %f %s to %f %s, expected %f", test.amount, test.source, converted, test.destination, test.expected)
+			}
+		})
+	}
+}
\ No newline at end of file
@@ -3,6 +3,7 @@ package sibylla
 import (
 	"fmt"
 	"log"
+	"math"
 	"path/filepath"
 	"runtime"
 	"runtime/debug"
@@ -15,13 +16,15 @@ import (
 )
 
 const dataMiningScript = "datamine.js"
-const hoursPerYear = 365.25 * 24
-const tradingDaysPerYear = 252
 const sharpeSegmentCount = 3
 const daysPerWeek = 5
 const weekdayOptimizationBuffer = 35
 const recentWeekdayPlotSamples = 100
 const stopLossAnalysisLimit = 1000
+const defaultMaxConditions = 2
+const conditionRangeLimit = 1.0 + 1e-3
+const searchModeGrid = "grid"
+const searchModeGenetic = "genetic"
 
 type DataMiningConfiguration struct {
 	Assets []string `yaml:"assets"`
@@ -39,14 +42,20 @@ type DataMiningConfiguration struct {
 	TradesMin int `yaml:"tradesMin"`
 	TradesRatio float64 `yaml:"tradesRatio"`
 	Conditions ConditionConfiguration `yaml:"conditions"`
+	MaxConditions int `yaml:"maxConditions"`
 	InitialCash *float64 `yaml:"initialCash"`
 	Leverage *float64 `yaml:"leverage"`
 	SingleFeature bool `yaml:"singleFeature"`
 	SeasonalityMode bool `yaml:"seasonalityMode"`
+	SeasonalityFeatures []string `yaml:"seasonalityFeatures"`
 	CorrelationSplits []SerializableDate `yaml:"correlationSplits"`
 	StrategyRatio *float64 `yaml:"strategyRatio"`
 	EnableStopLoss bool `yaml:"enableStopLoss"`
 	StopLoss []float64 `yaml:"stopLoss"`
+	Timezone string `yaml:"timezone"`
+	HoldingTimes []int `yaml:"holdingTimes"`
+	SearchMode string `yaml:"searchMode"`
+	Genetic *GeneticConfiguration `yaml:"genetic"`
 }
 
 type StrategyFilter struct {
@@ -64,6 +73,7 @@ type DataMiningModel struct {
 	DateMax string `json:"dateMax"`
 	TimeMin string `json:"timeMin"`
 	TimeMax string `json:"timeMax"`
+	Timezone string `json:"timezone"`
 	OptimizeWeekdays bool `json:"optimizeWeeks"`
 	Conditions *DataMiningConditions `json:"conditions"`
 	Results []AssetMiningResults `json:"results"`
@@ -71,6 +81,7 @@ type DataMiningModel struct {
 	SingleFeature bool `json:"singleFeature"`
 	SeasonalityMode bool `json:"seasonalityMode"`
 	EnableStopLoss bool `json:"enableStopLoss"`
+	Currency string `json:"currency"`
 }
 
 type DataMiningConditions struct {
@@ -135,7 +146,12 @@ type dataMiningTask struct {
 
 type seasonalityTask struct {
 	asset assetRecords
-	weekday time.Weekday
+	weekday *time.Weekday
+}
+
+type featureBounds struct {
+	min float64
+	max float64
 }
 
 func DataMine(yamlPath string) {
@@ -157,52 +173,107 @@ func executeDataMiningConfig(miningConfig DataMiningConfiguration) ([][]backtest
 		miningConfig.DateMax,
 		&miningConfig.TimeMin,
 		&miningConfig.TimeMax,
+		miningConfig.Timezone,
 	)
 	start := time.Now()
+	if miningConfig.SearchMode == searchModeGenetic {
+		taskResults := executeGeneticSearch(assetRecords, miningConfig)
+		delta := time.Since(start)
+		fmt.Printf("Finished genetic search in %.2f s\n", delta.Seconds())
+		return taskResults, assetRecords
+	}
 	tasks := getDataMiningTasks(assetRecords, miningConfig)
 	fmt.Println("Data mining strategies")
+	levelResults := executeDataMiningTasks(tasks, miningConfig)
+	taskResults := levelResults
+	for conditionCount := defaultMaxConditions + 1; conditionCount <= miningConfig.getMaxConditions(); conditionCount++ {
+		tasks = extendFeatureMiningTasks(tasks, levelResults, assetRecords, miningConfig)
+		if len(tasks) == 0 {
+			break
+		}
+		fmt.Printf("Data mining strategies with %d conditions\n", conditionCount)
+		levelResults = executeDataMiningTasks(tasks, miningConfig)
+		taskResults = append(taskResults, levelResults...)
+	}
+	delta := time.Since(start)
+	fmt.Printf("Finished data mining in %.2f s\n", delta.Seconds())
+	return taskResults, assetRecords
+}
+
+func executeDataMiningTasks(tasks []dataMiningTask, miningConfig DataMiningConfiguration) [][]backtestData {
 	bar := pb.StartNew(len(tasks))
 	bar.Start()
 	taskResults := parallelMap(tasks, func (task dataMiningTask) []backtestData {
 		return executeDataMiningTask(task, bar, miningConfig)
 	})
 	bar.Finish()
-	delta := time.Since(start)
-	fmt.Printf("Finished data mining in %.2f s\n", delta.Seconds())
-	return taskResults, assetRecords
+	return taskResults
 }
 
 func getDataMiningTasks(assetRecords []assetRecords, miningConfig DataMiningConfiguration) []dataMiningTask {
 	if miningConfig.SeasonalityMode {
-		return getSeasonalityMiningTasks(assetRecords)
+		return getSeasonalityMiningTasks(assetRecords, miningConfig)
 	} else {
 		return getFeatureMiningTasks(assetRecords, miningConfig)
 	}
 }
 
-func getSeasonalityMiningTasks(assetRecords []assetRecords) []dataMiningTask {
+func getSeasonalityMiningTasks(assetRecords []assetRecords, miningConfig DataMiningConfiguration) []dataMiningTask {
+	accessors := []featureAccessor{}
+	for _, name := range miningConfig.SeasonalityFeatures {
+		accessor, exists := find(getFeatureAccessors(), func (f featureAccessor) bool {
+			return f.name == name
+		})
+		if !exists || !accessor.discrete {
+			log.Fatalf("Seasonality features must be calendar or event features: %s", name)
+		}
+		accessors = append(accessors, accessor)
+	}
 	tasks := []dataMiningTask{}
 	for _, asset := range assetRecords {
 		for weekday := time.Monday; weekday <= time.Friday; weekday++ {
 			seasonality := seasonalityTask{
 				asset: asset,
-				weekday: weekday,
+				weekday: &weekday,
 			}
 			task := dataMiningTask{
 				seasonality: &seasonality,
 			}
 			tasks = append(tasks, task)
 		}
+		// Each distinct value of a calendar feature is scanned like a weekday
+		for _, accessor := range accessors {
+			values := []float64{}
+			for i := range asset.intradayRecords {
+				value := accessor.get(&asset.intradayRecords[i])
+				if value != nil && !slices.Contains(values, *value) {
+					values = append(values, *value)
+				}
+			}
+			slices.Sort(values)
+			for _, value := range values {
+				seasonality := seasonalityTask{
+					asset: asset,
+				}
+				task := dataMiningTask{
+					conditions: []strategyCondition{
+						newDataMiningParameter(asset, accessor, value, value),
+					},
+					seasonality: &seasonality,
+				}
+				tasks = append(tasks, task)
+			}
+		}
 	}
 	return tasks
 }
 
 func getFeatureMiningTasks(assetRecords []assetRecords, miningConfig DataMiningConfiguration) []dataMiningTask {
-	accessors := getFeatureAccessors()
+	accessors := getMiningFeatureAccessors()
+	bounds := getFeatureBounds(assetRecords, accessors)
 	tasks := []dataMiningTask{}
 	conditionRange := miningConfig.Conditions.Range
 	increment := miningConfig.Conditions.Increment
-	const epsilonLimit = 1.0 + 1e-3
 	singleFeature := miningConfig.SingleFeature
 	for i, asset1 := range assetRecords {
 		if asset1.asset.FeaturesOnly || slices.Contains(miningConfig.FeaturesOnly, asset1.asset.Symbol) {
@@ -217,15 +288,17 @@ func getFeatureMiningTasks(assetRecords []assetRecords, miningConfig DataMiningC
 					if singleFeature && (i != j || k != l) {
 						continue
 					}
-					for min1 := 0.0; min1 + conditionRange <= epsilonLimit; min1 += increment {
-						for min2 := 0.0; min2 + conditionRange <= epsilonLimit; min2 += increment {
+					for min1 := 0.0; min1 + conditionRange <= conditionRangeLimit; min1 += increment {
+						for min2 := 0.0; min2 + conditionRange <= conditionRangeLimit; min2 += increment {
 							if singleFeature && min1 != min2 {
 								continue
 							}
 							max1 := min1 + conditionRange
 							max2 := min2 + conditionRange
-							parameter1 := newDataMiningParameter(asset1, feature1, min1, max1)
-							parameter2 := newDataMiningParameter(asset2, feature2, min2, max2)
+							bounds1 := bounds[i][k]
+							bounds2 := bounds[j][l]
+							parameter1 := newDataMiningParameter(asset1, feature1, bounds1.scale(min1), bounds1.scale(max1))
+							parameter2 := newDataMiningParameter(asset2, feature2, bounds2.scale(min2), bounds2.scale(max2))
 							parameters := []strategyCondition{
 								parameter1,
 								parameter2,
@@ -243,6 +316,99 @@ func getFeatureMiningTasks(assetRecords []assetRecords, miningConfig DataMiningC
 	return tasks
 }
 
+// Adding a condition can only reduce the number of trades, so strategies that failed tradesMin or the strategy filter are not extended.
+// The conditions following the first one are kept in order to avoid evaluating permutations of the same strategy.
+func extendFeatureMiningTasks(
+	parents []dataMiningTask,
+	parentResults [][]backtestData,
+	assetRecords []assetRecords,
+	miningConfig DataMiningConfiguration,
+) []dataMiningTask {
+	accessors := getMiningFeatureAccessors()
+	bounds := getFeatureBounds(assetRecords, accessors)
+	conditionRange := miningConfig.Conditions.Range
+	increment := miningConfig.Conditions.Increment
+	getIndexes := func (condition strategyCondition) (int, int) {
+		assetIndex := -1
+		for i, records := range assetRecords {
+			if records.asset.Symbol == condition.asset.asset.Symbol {
+				assetIndex = i
+				break
+			}
+		}
+		featureIndex := slices.IndexFunc(accessors, func (f featureAccessor) bool {
+			return f.name == condition.feature.name
+		})
+		return assetIndex, featureIndex
+	}
+	tasks := []dataMiningTask{}
+	for i, parent := range parents {
+		passed := slices.ContainsFunc(parentResults[i], func (backtest backtestData) bool {
+			return !backtest.filtered
+		})
+		if !passed {
+			continue
+		}
+		firstAsset, firstFeature := getIndexes(parent.conditions[0])
+		lastAsset, lastFeature := getIndexes(parent.conditions[len(parent.conditions) - 1])
+		for j, asset := range assetRecords {
+			for l, feature := range accessors {
+				if j < lastAsset || (j == lastAsset && l <= lastFeature) {
+					continue
+				}
+				if j == firstAsset && l <= firstFeature {
+					continue
+				}
+				for minimum := 0.0; minimum + conditionRange <= conditionRangeLimit; minimum += increment {
+					maximum := minimum + conditionRange
+					parameter := newDataMiningParameter(asset, feature, bounds[j][l].scale(minimum), bounds[j][l].scale(maximum))
+					task := dataMiningTask{
+						conditions: append(slices.Clone(parent.conditions), parameter),
+					}
+					tasks = append(tasks, task)
+				}
+			}
+		}
+	}
+	return tasks
+}
+
+// Quantiles are mined in [0, 1], discrete features in the range of values observed in the records of each asset
+func getFeatureBounds(assetRecords []assetRecords, accessors []featureAccessor) [][]featureBounds {
+	output := [][]featureBounds{}
+	for _, asset := range assetRecords {
+		assetBounds := []featureBounds{}
+		for _, accessor := range accessors {
+			bounds := featureBounds{
+				min: 0.0,
+				max: 1.0,
+			}
+			if accessor.discrete {
+				bounds.min = math.Inf(1)
+				bounds.max = math.Inf(-1)
+				for i := range asset.intradayRecords {
+					value := accessor.get(&asset.intradayRecords[i])
+					if value != nil {
+						bounds.min = math.Min(bounds.min, *value)
+						bounds.max = math.Max(bounds.max, *value)
+					}
+				}
+				if bounds.min > bounds.max {
+					bounds.min = 0.0
+					bounds.max = 0.0
+				}
+			}
+			assetBounds = append(assetBounds, bounds)
+		}
+		output = append(output, assetBounds)
+	}
+	return output
+}
+
+func (b featureBounds) scale(x float64) float64 {
+	return b.min + x * (b.max - b.min)
+}
+
 func processResults(
 	taskResults [][]backtestData,
 	assetRecords []assetRecords,
@@ -342,7 +508,10 @@ func executeSeasonalityMiningTask(task dataMiningTask, miningConfig DataMiningCo
 		if !record.hasReturns() {
 			continue
 		}
-		if record.Timestamp.Weekday() != task.seasonality.weekday {
+		if task.seasonality.weekday != nil && record.localTime.Weekday() != *task.seasonality.weekday {
+			continue
+		}
+		if len(task.conditions) > 0 && !task.conditions[0].match(record) {
 			continue
 		}
 		for j := range backtests {
@@ -356,16 +525,28 @@ func executeSeasonalityMiningTask(task dataMiningTask, miningConfig DataMiningCo
 }
 
 func executeFeatureMiningTask(task dataMiningTask, miningConfig DataMiningConfiguration) []backtestData {
-	condition1 := &task.conditions[0]
-	condition2 := &task.conditions[1]
 	backtests := initializeMiningBacktests(task, miningConfig)
+	runFeatureMiningBacktests(task, backtests, miningConfig)
+	return backtests
+}
+
+func runFeatureMiningBacktests(task dataMiningTask, backtests []backtestData, miningConfig DataMiningConfiguration) {
+	condition1 := &task.conditions[0]
 	for i := range condition1.asset.intradayRecords {
 		record1 := &condition1.asset.intradayRecords[i]
 		if !record1.hasReturns() || !condition1.match(record1) {
 			continue
 		}
-		record2, exists := condition2.asset.recordsMap[record1.Timestamp]
-		if !exists || !condition2.match(record2) {
+		match := true
+		for j := 1; j < len(task.conditions); j++ {
+			condition := &task.conditions[j]
+			record, exists := condition.asset.recordsMap[record1.Timestamp]
+			if !exists || !condition.match(record) {
+				match = false
+				break
+			}
+		}
+		if !match {
 			continue
 		}
 		asset := &condition1.asset.asset
@@ -376,7 +557,6 @@ func executeFeatureMiningTask(task dataMiningTask, miningConfig DataMiningConfig
 		drawdownAndTradesCheck(backtests, miningConfig)
 	}
 	postProcessBacktests(condition1.asset.intradayRecords, backtests, miningConfig)
-	return backtests
 }
 
 func drawdownAndTradesCheck(backtests []backtestData, miningConfig DataMiningConfiguration) {
@@ -393,7 +573,9 @@ func drawdownAndTradesCheck(backtests []backtestData, miningConfig DataMiningCon
 				enoughSamples = false
 				badPerformance = false
 			}
-			if drawdownExceeded || (enoughSamples && badPerformance) {
+			filtered := enoughSamples && badPerformance
+			if drawdownExceeded || filtered {
+				backtest.filtered = filtered
 				backtest.disable()
 			}
 		}
@@ -431,41 +613,20 @@ func initializeMiningBacktests(task dataMiningTask, miningConfig DataMiningConfi
 	if miningConfig.OptimizeWeekdays {
 		optimizeWeekdaysModes = append(optimizeWeekdaysModes, true)
 	}
-	returnsAccessors := getReturnsAccessors()
-	var symbol string
+	returnsAccessors := miningConfig.getReturnsAccessors()
+	var tradedAsset *assetRecords
 	if task.seasonality != nil {
-		symbol = task.seasonality.asset.asset.Symbol
+		tradedAsset = &task.seasonality.asset
 	} else {
-		symbol = task.conditions[0].asset.asset.Symbol
+		tradedAsset = &task.conditions[0].asset
 	}
 	for _, returns := range returnsAccessors {
 		stopLossLimits := getStopLossLimits(miningConfig)
 		for _, stopLoss := range stopLossLimits {
 			for _, side := range sides {
 				for _, optimizeWeekdays := range optimizeWeekdaysModes {
-					for timeOfDay := miningConfig.TimeMin.Duration;
-						timeOfDay <= miningConfig.TimeMax.Duration;
-						timeOfDay += time.Duration(1) * time.Hour {
-						backtest := newBacktest(
-							symbol,
-							side,
-							&timeOfDay,
-							task.conditions,
-							returns,
-							*miningConfig.InitialCash,
-						)
-						backtest.optimizeWeekdays = optimizeWeekdays
-						if task.seasonality != nil {
-							backtest.seasonalityMode = true
-							backtest.weekday = &task.seasonality.weekday
-						}
-						if miningConfig.EnableStopLoss && stopLoss != nil {
-							backtest.enableStopLoss = miningConfig.EnableStopLoss
-							backtest.stopLoss = stopLoss
-						}
-						for i := range backtest.optimizationReturns {
-							backtest.optimizationReturns[i].SetBaseCap(weekdayOptimizationBuffer + 2)
-						}
+					for _, timeOfDay := range miningConfig.getTimesOfDay() {
+						backtest := newMiningBacktest(task, tradedAsset, side, timeOfDay, returns, stopLoss, optimizeWeekdays, miningConfig)
 						backtests = append(backtests, backtest)
 					}
 				}
@@ -475,6 +636,53 @@ func initializeMiningBacktests(task dataMiningTask, miningConfig DataMiningConfi
 	return backtests
 }
 
+func newMiningBacktest(
+	task dataMiningTask,
+	tradedAsset *assetRecords,
+	side PositionSide,
+	timeOfDay time.Duration,
+	returns returnsAccessor,
+	stopLoss *float64,
+	optimizeWeekdays bool,
+	miningConfig DataMiningConfiguration,
+) backtestData {
+	asset := &tradedAsset.asset
+	backtest := newBacktest(
+		asset.Symbol,
+		asset.getCalendar(),
+		side,
+		&timeOfDay,
+		task.conditions,
+		returns,
+		*miningConfig.InitialCash,
+	)
+	backtest.optimizeWeekdays = optimizeWeekdays
+	if task.seasonality != nil {
+		backtest.seasonalityMode = true
+		backtest.weekday = task.seasonality.weekday
+	}
+	if miningConfig.EnableStopLoss && stopLoss != nil {
+		backtest.enableStopLoss = miningConfig.EnableStopLoss
+		backtest.stopLoss = stopLoss
+		// Stop-losses are walked over the same hourly bars as in backtests, archives without bars fall back to the returns records
+		if len(tradedAsset.bars) > 0 {
+			backtest.exits = newBarExits(tradedAsset, nil, nil)
+		}
+	}
+	for i := range backtest.optimizationReturns {
+		backtest.optimizationReturns[i].SetBaseCap(weekdayOptimizationBuffer + 2)
+	}
+	return backtest
+}
+
+func (c *DataMiningConfiguration) getTimesOfDay() []time.Duration {
+	timesOfDay := []time.Duration{}
+	for timeOfDay := c.TimeMin.Duration; timeOfDay <= c.TimeMax.Duration; timeOfDay += time.Duration(1) * time.Hour {
+		timesOfDay = append(timesOfDay, timeOfDay)
+	}
+	return timesOfDay
+}
+
 func getStopLossLimits(miningConfig DataMiningConfiguration) []*float64 {
 	stopLossLimits := []*float64{nil}
 	if miningConfig.EnableStopLoss {
@@ -532,6 +740,9 @@ func postProcessBacktests(intradayRecords []FeatureRecord, backtests []backtestD
 	for i := range backtests {
 		backtest := &backtests[i]
 		if len(backtest.equityCurve.samples) < miningConfig.TradesMin {
+			if backtest.enabled {
+				backtest.filtered = true
+			}
 			backtest.disable()
 			continue
 		}
@@ -582,7 +793,8 @@ func getAssetReturns(side PositionSide, timestamp time.Time, ticks int, enableFe
 		gains = - gains
 	}
 	if enableFees {
-		fees := asset.BrokerFee + asset.ExchangeFee
+		// Fees are specified in USD
+		fees := convertCurrency(timestamp, asset.BrokerFee + asset.ExchangeFee, currencyUSD)
 		gains -= fees
 	}
 	return gains
@@ -598,6 +810,21 @@ func (c *strategyCondition) match(record *FeatureRecord) bool {
 	return match
 }
 
+func (c *DataMiningConfiguration) getReturnsAccessors() []returnsAccessor {
+	if len(c.HoldingTimes) == 0 {
+		return getReturnsAccessors()
+	}
+	accessors := []returnsAccessor{}
+	for _, holdingTime := range c.HoldingTimes {
+		accessor, exists := getReturnsAccessor(holdingTime)
+		if !exists {
+			log.Fatalf("Holding time %dh is not one of the holdingTimes in %s", holdingTime, configurationPath)
+		}
+		accessors = append(accessors, accessor)
+	}
+	return accessors
+}
+
 func loadDataMiningConfiguration(path string) DataMiningConfiguration {
 	yamlData := readFile(path)
 	configuration := new(DataMiningConfiguration)
@@ -629,6 +856,12 @@ func (c *DataMiningConfiguration) validate() {
 	if c.Conditions.Increment == 0.0 || c.Conditions.Range == 0.0 {
 		log.Fatal("Invalid condition configuration")
 	}
+	if c.MaxConditions != 0 && c.MaxConditions < defaultMaxConditions {
+		log.Fatalf("Invalid maximum number of conditions: %d", c.MaxConditions)
+	}
+	if c.getMaxConditions() > defaultMaxConditions && (c.SingleFeature || c.SeasonalityMode) {
+		log.Fatal("More than two conditions are not supported in single feature and seasonality mode")
+	}
 	if !c.DateMin.Before(c.DateMax.Time) {
 		format := "Invalid dateMin/dateMax values in data mining configuration: %s vs. %s"
 		log.Fatalf(format, getDateString(c.DateMin.Time), getDateString(c.DateMax.Time))
@@ -656,6 +889,33 @@ func (c *DataMiningConfiguration) validate() {
 			}
 		}
 	}
+	switch c.SearchMode {
+	case "", searchModeGrid:
+	case searchModeGenetic:
+		if c.Genetic == nil {
+			log.Fatal("Genetic search requires a genetic configuration")
+		}
+		if c.SeasonalityMode || c.isCorrelation() {
+			log.Fatal("Genetic search is not supported in seasonality mode and correlation analysis")
+		}
+		c.Genetic.validate()
+	default:
+		log.Fatalf("Invalid search mode \"%s\"", c.SearchMode)
+	}
+}
+
+func (c *DataMiningConfiguration) getTimezoneString() string {
+	if c.Timezone == "" {
+		return timezoneExchange
+	}
+	return c.Timezone
+}
+
+func (c *DataMiningConfiguration) getMaxConditions() int {
+	if c.MaxConditions == 0 {
+		return defaultMaxConditions
+	}
+	return c.MaxConditions
 }
 
 func (c *DataMiningConfiguration) isCorrelation() bool {
@@ -676,12 +936,14 @@ func getDataMiningModel(
 		DateMax: getDateString(miningConfig.DateMax.Time),
 		TimeMin: getTimeOfDayString(miningConfig.TimeMin.Duration),
 		TimeMax: getTimeOfDayString(miningConfig.TimeMax.Duration),
+		Timezone: miningConfig.getTimezoneString(),
 		OptimizeWeekdays: miningConfig.OptimizeWeekdays,
 		Results: []AssetMiningResults{},
 		Features: features,
 		SingleFeature: miningConfig.SingleFeature,
 		SeasonalityMode: miningConfig.SeasonalityMode,
 		EnableStopLoss: miningConfig.EnableStopLoss,
+		Currency: getAccountCurrency(),
 	}
 	if !miningConfig.SeasonalityMode {
 		conditions := DataMiningConditions{
@@ -734,7 +996,7 @@ func getDataMiningModel(
 }
 
 func getStopLossAnalysis(backtests []backtestData, miningConfig DataMiningConfiguration) StopLossAnalysis {
-	returns := getReturnsAccessors()
+	returns := miningConfig.getReturnsAccessors()
 	holdingTimes := []int{}
 	holdingTimesIndices := map[int]int{}
 	sharpeRatioSamples := [][][]float64{}
@@ -871,6 +1133,7 @@ func getTradesRatio(
 	dateMax time.Time,
 	equityCurve equityCurveData,
 	intradayRecords []FeatureRecord,
+	calendar *tradingCalendar,
 ) float64 {
 	recordsFirst := intradayRecords[0].Timestamp
 	recordsLast := intradayRecords[len(intradayRecords) - 1].Timestamp
@@ -882,15 +1145,14 @@ func getTradesRatio(
 	if recordsLast.After(end) {
 		end = recordsLast
 	}
-	duration := end.Sub(start)
 	daysTradedMap := map[time.Time]struct{}{}
 	for _, record := range equityCurve.samples {
 		date := getDateFromTime(record.timestamp)
 		daysTradedMap[date] = struct{}{}
 	}
 	daysTraded := len(daysTradedMap)
-	years := duration.Hours() / hoursPerYear
-	tradesRatio := float64(daysTraded) / (tradingDaysPerYear * years)
+	tradingDays := calendar.countTradingDays(start, end)
+	tradesRatio := float64(daysTraded) / float64(tradingDays)
 	return tradesRatio
 }
 
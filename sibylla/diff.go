@@ -0,0 +1,373 @@
+package sibylla
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const diffDefaultPath = "diff.json"
+const diffDetailLimit = 1000
+const diffTolerance = 1e-9
+
+type ArchiveDiff struct {
+	PathA string `json:"pathA"`
+	PathB string `json:"pathB"`
+	RecordsA int `json:"recordsA"`
+	RecordsB int `json:"recordsB"`
+	Common int `json:"common"`
+	Added []string `json:"added"`
+	Removed []string `json:"removed"`
+	FeaturesAdded []string `json:"featuresAdded"`
+	FeaturesRemoved []string `json:"featuresRemoved"`
+	Features []FeatureDiff `json:"features"`
+	ContractChanges int `json:"contractChanges"`
+	Contracts []ContractDiff `json:"contracts"`
+	Returns []ReturnsDiff `json:"returns"`
+}
+
+type FeatureDiff struct {
+	Name string `json:"name"`
+	Changed int `json:"changed"`
+	MaxDelta float64 `json:"maxDelta"`
+	MeanDelta float64 `json:"meanDelta"`
+	A DistributionStats `json:"a"`
+	B DistributionStats `json:"b"`
+	// Two-sample Kolmogorov-Smirnov statistic of all values in A and B
+	KolmogorovSmirnov float64 `json:"kolmogorovSmirnov"`
+	Differences []ValueDiff `json:"differences"`
+}
+
+type DistributionStats struct {
+	Count int `json:"count"`
+	Missing int `json:"missing"`
+	Mean float64 `json:"mean"`
+	StdDev float64 `json:"stdDev"`
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+type ValueDiff struct {
+	Time string `json:"time"`
+	A *float64 `json:"a"`
+	B *float64 `json:"b"`
+}
+
+type ContractDiff struct {
+	Time string `json:"time"`
+	A string `json:"a"`
+	B string `json:"b"`
+}
+
+type ReturnsDiff struct {
+	Name string `json:"name"`
+	Changed int `json:"changed"`
+	Differences []ReturnsValueDiff `json:"differences"`
+}
+
+type ReturnsValueDiff struct {
+	Time string `json:"time"`
+	A *ReturnsRecord `json:"a"`
+	B *ReturnsRecord `json:"b"`
+}
+
+type alignedRecords struct {
+	a *FeatureRecord
+	b *FeatureRecord
+}
+
+func DiffArchives(pathA, pathB string) {
+	loadConfiguration()
+	start := time.Now()
+	archives := parallelMap([]string{pathA, pathB}, readArchive)
+	archiveA := archives[0]
+	archiveB := archives[1]
+	diff := ArchiveDiff{
+		PathA: pathA,
+		PathB: pathB,
+		RecordsA: len(archiveA.IntradayRecords),
+		RecordsB: len(archiveB.IntradayRecords),
+		Added: []string{},
+		Removed: []string{},
+		FeaturesAdded: []string{},
+		FeaturesRemoved: []string{},
+		Contracts: []ContractDiff{},
+	}
+	aligned := alignArchiveRecords(&archiveA, &archiveB, &diff)
+	diff.Common = len(aligned)
+	for _, name := range archiveA.Features {
+		if !slices.Contains(archiveB.Features, name) {
+			diff.FeaturesRemoved = append(diff.FeaturesRemoved, name)
+		}
+	}
+	for i, name := range archiveB.Features {
+		indexA := slices.Index(archiveA.Features, name)
+		if indexA < 0 {
+			diff.FeaturesAdded = append(diff.FeaturesAdded, name)
+			continue
+		}
+		featureDiff := diffFeature(name, indexA, i, &archiveA, &archiveB, aligned)
+		diff.Features = append(diff.Features, featureDiff)
+	}
+	for i, holdingTime := range archiveB.HoldingTimes {
+		indexA := slices.Index(archiveA.HoldingTimes, holdingTime)
+		if indexA < 0 {
+			continue
+		}
+		returnsDiff := diffReturns(getReturnsName(holdingTime), indexA, i, aligned)
+		diff.Returns = append(diff.Returns, returnsDiff)
+	}
+	diffContracts(&archiveA, &archiveB, aligned, &diff)
+	jsonBytes, err := json.MarshalIndent(diff, "", "\t")
+	if err != nil {
+		log.Fatal("Failed to serialize archive diff to JSON:", err)
+	}
+	path := diffDefaultPath
+	if configuration.DiffPath != "" {
+		path = configuration.DiffPath
+	}
+	writeFile(path, string(jsonBytes))
+	delta := time.Since(start)
+	fmt.Printf("Compared archives in %.2f s\n\n", delta.Seconds())
+	diff.print()
+	fmt.Printf("\nWrote detailed diff to %s\n", path)
+}
+
+func alignArchiveRecords(archiveA, archiveB *Archive, diff *ArchiveDiff) []alignedRecords {
+	aligned := []alignedRecords{}
+	recordsA := archiveA.IntradayRecords
+	recordsB := archiveB.IntradayRecords
+	i := 0
+	j := 0
+	for i < len(recordsA) || j < len(recordsB) {
+		if j >= len(recordsB) || (i < len(recordsA) && recordsA[i].Timestamp.Before(recordsB[j].Timestamp)) {
+			diff.Removed = append(diff.Removed, getTimeString(recordsA[i].Timestamp))
+			i++
+		} else if i >= len(recordsA) || recordsB[j].Timestamp.Before(recordsA[i].Timestamp) {
+			diff.Added = append(diff.Added, getTimeString(recordsB[j].Timestamp))
+			j++
+		} else {
+			pair := alignedRecords{
+				a: &recordsA[i],
+				b: &recordsB[j],
+			}
+			aligned = append(aligned, pair)
+			i++
+			j++
+		}
+	}
+	return aligned
+}
+
+func diffFeature(name string, indexA, indexB int, archiveA, archiveB *Archive, aligned []alignedRecords) FeatureDiff {
+	getValue := func (record *FeatureRecord, index int) *float64 {
+		value := record.Features[index]
+		if math.IsNaN(value) {
+			return nil
+		}
+		return &value
+	}
+	featureDiff := FeatureDiff{
+		Name: name,
+		Differences: []ValueDiff{},
+	}
+	deltaSum := 0.0
+	deltaCount := 0
+	for _, pair := range aligned {
+		a := getValue(pair.a, indexA)
+		b := getValue(pair.b, indexB)
+		if a == nil && b == nil {
+			continue
+		}
+		if a != nil && b != nil {
+			delta := math.Abs(*b - *a)
+			if delta <= diffTolerance {
+				continue
+			}
+			featureDiff.MaxDelta = math.Max(featureDiff.MaxDelta, delta)
+			deltaSum += delta
+			deltaCount++
+		}
+		featureDiff.Changed++
+		if len(featureDiff.Differences) < diffDetailLimit {
+			valueDiff := ValueDiff{
+				Time: getTimeString(pair.a.Timestamp),
+				A: a,
+				B: b,
+			}
+			featureDiff.Differences = append(featureDiff.Differences, valueDiff)
+		}
+	}
+	if deltaCount > 0 {
+		featureDiff.MeanDelta = deltaSum / float64(deltaCount)
+	}
+	valuesA := getFeatureColumn(archiveA, indexA)
+	valuesB := getFeatureColumn(archiveB, indexB)
+	featureDiff.A = getDistributionStats(valuesA, len(archiveA.IntradayRecords))
+	featureDiff.B = getDistributionStats(valuesB, len(archiveB.IntradayRecords))
+	featureDiff.KolmogorovSmirnov = getKolmogorovSmirnov(valuesA, valuesB)
+	return featureDiff
+}
+
+func getFeatureColumn(archive *Archive, index int) []float64 {
+	values := []float64{}
+	for _, record := range archive.IntradayRecords {
+		value := record.Features[index]
+		if !math.IsNaN(value) {
+			values = append(values, value)
+		}
+	}
+	sort.Float64s(values)
+	return values
+}
+
+func getDistributionStats(values []float64, records int) DistributionStats {
+	stats := DistributionStats{
+		Count: len(values),
+		Missing: records - len(values),
+	}
+	if len(values) == 0 {
+		return stats
+	}
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+	stats.Mean = sum / float64(len(values))
+	if len(values) > 1 {
+		deltaSum := 0.0
+		for _, value := range values {
+			delta := value - stats.Mean
+			deltaSum += delta * delta
+		}
+		stats.StdDev = math.Sqrt(deltaSum / float64(len(values) - 1))
+	}
+	stats.Min = values[0]
+	stats.Max = values[len(values) - 1]
+	return stats
+}
+
+// Both slices must be sorted
+func getKolmogorovSmirnov(a, b []float64) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0.0
+	}
+	i := 0
+	j := 0
+	maxDistance := 0.0
+	for i < len(a) && j < len(b) {
+		value := math.Min(a[i], b[j])
+		for i < len(a) && a[i] <= value {
+			i++
+		}
+		for j < len(b) && b[j] <= value {
+			j++
+		}
+		distance := math.Abs(float64(i) / float64(len(a)) - float64(j) / float64(len(b)))
+		maxDistance = math.Max(maxDistance, distance)
+	}
+	return maxDistance
+}
+
+func diffReturns(name string, indexA, indexB int, aligned []alignedRecords) ReturnsDiff {
+	returnsDiff := ReturnsDiff{
+		Name: name,
+		Differences: []ReturnsValueDiff{},
+	}
+	getReturns := func (record *FeatureRecord, index int) *ReturnsRecord {
+		returns := record.Returns[index]
+		if !returns.Valid {
+			return nil
+		}
+		return &returns
+	}
+	for _, pair := range aligned {
+		a := getReturns(pair.a, indexA)
+		b := getReturns(pair.b, indexB)
+		if a == nil && b == nil {
+			continue
+		}
+		if a != nil && b != nil && *a == *b {
+			continue
+		}
+		returnsDiff.Changed++
+		if len(returnsDiff.Differences) < diffDetailLimit {
+			valueDiff := ReturnsValueDiff{
+				Time: getTimeString(pair.a.Timestamp),
+				A: a,
+				B: b,
+			}
+			returnsDiff.Differences = append(returnsDiff.Differences, valueDiff)
+		}
+	}
+	return returnsDiff
+}
+
+func diffContracts(archiveA, archiveB *Archive, aligned []alignedRecords, diff *ArchiveDiff) {
+	for _, pair := range aligned {
+		timestamp := pair.a.Timestamp.Unix()
+		contractA := getSelectedContract(archiveA.Bars, timestamp)
+		contractB := getSelectedContract(archiveB.Bars, timestamp)
+		if contractA == contractB {
+			continue
+		}
+		diff.ContractChanges++
+		if len(diff.Contracts) < diffDetailLimit {
+			contractDiff := ContractDiff{
+				Time: getTimeString(pair.a.Timestamp),
+				A: contractA,
+				B: contractB,
+			}
+			diff.Contracts = append(diff.Contracts, contractDiff)
+		}
+	}
+}
+
+func getSelectedContract(bars []BarSeries, timestamp int64) string {
+	exits := barExits{
+		bars: bars,
+	}
+	series, _, exists := exits.getSeries(timestamp)
+	if !exists {
+		return ""
+	}
+	return series.Symbol
+}
+
+func (d *ArchiveDiff) print() {
+	fmt.Printf("A: %s (%d records)\n", d.PathA, d.RecordsA)
+	fmt.Printf("B: %s (%d records)\n", d.PathB, d.RecordsB)
+	fmt.Printf("Common timestamps: %d, added: %d, removed: %d\n", d.Common, len(d.Added), len(d.Removed))
+	if len(d.FeaturesAdded) > 0 {
+		fmt.Printf("Features added: %s\n", strings.Join(d.FeaturesAdded, ", "))
+	}
+	if len(d.FeaturesRemoved) > 0 {
+		fmt.Printf("Features removed: %s\n", strings.Join(d.FeaturesRemoved, ", "))
+	}
+	fmt.Printf("Front contract differences: %d\n\n", d.ContractChanges)
+	format := "%-24s %9s %12s %12s %12s %12s %12s %12s %8s\n"
+	fmt.Printf(format, "Feature", "Changed", "Max Delta", "Mean Delta", "Mean A", "Mean B", "SD A", "SD B", "KS")
+	for _, feature := range d.Features {
+		fmt.Printf(
+			format,
+			feature.Name,
+			fmt.Sprintf("%.2f%%", getPercentageFromInts(feature.Changed, max(d.Common, 1))),
+			fmt.Sprintf("%.4g", feature.MaxDelta),
+			fmt.Sprintf("%.4g", feature.MeanDelta),
+			fmt.Sprintf("%.4g", feature.A.Mean),
+			fmt.Sprintf("%.4g", feature.B.Mean),
+			fmt.Sprintf("%.4g", feature.A.StdDev),
+			fmt.Sprintf("%.4g", feature.B.StdDev),
+			fmt.Sprintf("%.3f", feature.KolmogorovSmirnov),
+		)
+	}
+	fmt.Println("")
+	for _, returns := range d.Returns {
+		fmt.Printf("%s: %d changed records\n", returns.Name, returns.Changed)
+	}
+}
\ No newline at end of file
@@ -3,14 +3,11 @@ package sibylla
 import (
 	"log"
 	"math"
-	"strconv"
 	"time"
 
 	"gonum.org/v1/gonum/stat"
 )
 
-var riskFreeRate map[monthlyEquityKey]float64
-
 type equityCurveSample struct {
 	timestamp time.Time
 	cash float64
@@ -167,10 +164,7 @@ func (d *equityCurveData) getSharpe(
 	riskFreeRateSamples := []float64{}
 	for date := dateMin; date.Before(dateMax); date = date.AddDate(0, 1, 0) {
 		key := newMonthlyEquityKey(date)
-		rate, exists := riskFreeRate[key]
-		if !exists {
-			log.Fatalf("Unable to find a risk free rate sample for %s", getDateString(date))
-		}
+		rate := riskFreeRate.getRate(key)
 		riskFreeRateSamples = append(riskFreeRateSamples, rate)
 	}
 	annualRate := stat.Mean(riskFreeRateSamples, nil) / 100.0
@@ -183,25 +177,4 @@ func (d *equityCurveData) getSharpe(
 func (d *equityCurveData) reset() {
 	d.samples = nil
 	d.endOfMonthCash = nil
-}
-
-func loadRiskFreeRate() {
-	if riskFreeRate != nil {
-		return
-	}
-	columns := []string{
-		"observation_date",
-		"TB3MS",
-	}
-	riskFreeRate = map[monthlyEquityKey]float64{}
-	readCsv(configuration.RiskFreeRatePath, columns, func (values []string) {
-		date := getDate(values[0])
-		key := newMonthlyEquityKey(date)
-		rateString := values[1]
-		rate, err := strconv.ParseFloat(rateString, 64)
-		if err != nil {
-			log.Fatalf("Failed to parse rate value \"%s\": %v", rateString, err)
-		}
-		riskFreeRate[key] = rate
-	})
 }
\ No newline at end of file
@@ -0,0 +1,157 @@
+package sibylla
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+)
+
+const defaultEventsPath = "configuration/events.csv"
+
+const featureTradingDayOfMonth = "tradingDayOfMonth"
+const featureTradingDaysToMonthEnd = "tradingDaysToMonthEnd"
+const featureDaysToExpiry = "daysToExpiry"
+const featureEvent = "event"
+
+// Dates on which an event is scheduled, sessions outside of the first and the last date are not covered by the events file
+type eventDates struct {
+	dates map[time.Time]struct{}
+	first time.Time
+	last time.Time
+}
+
+var events map[string]*eventDates
+
+func loadEvents() {
+	if events != nil {
+		panic("Events had already been loaded")
+	}
+	events = map[string]*eventDates{}
+	eventFeatures := []FeatureDefinition{}
+	for _, definition := range *featureCatalog {
+		if definition.Type == featureEvent {
+			eventFeatures = append(eventFeatures, definition)
+		}
+	}
+	if len(eventFeatures) == 0 {
+		return
+	}
+	path := defaultEventsPath
+	if configuration.EventsPath != "" {
+		path = configuration.EventsPath
+	}
+	columns := []string{
+		"date",
+		"event",
+	}
+	readCsv(path, columns, func (values []string) {
+		date := getDate(values[0])
+		name := values[1]
+		event, exists := events[name]
+		if !exists {
+			event = &eventDates{
+				dates: map[time.Time]struct{}{},
+				first: date,
+				last: date,
+			}
+			events[name] = event
+		}
+		event.dates[date] = struct{}{}
+		if date.Before(event.first) {
+			event.first = date
+		}
+		if date.After(event.last) {
+			event.last = date
+		}
+	})
+	for _, definition := range eventFeatures {
+		_, exists := events[definition.Event]
+		if !exists {
+			log.Fatalf("Unable to find event \"%s\" of feature \"%s\" in %s", definition.Event, definition.Name, path)
+		}
+	}
+}
+
+// Calendar features take on few distinct values, which rank normalization would distort
+func (d *FeatureDefinition) isDiscrete() bool {
+	discreteTypes := []string{
+		featureTradingDayOfMonth,
+		featureTradingDaysToMonthEnd,
+		featureDaysToExpiry,
+		featureEvent,
+	}
+	return slices.Contains(discreteTypes, d.Type)
+}
+
+func validateCalendarFeature(definition *FeatureDefinition) error {
+	if definition.Days > 0 || definition.Hours > 0 || definition.LagDays > 0 || definition.Window > 0 {
+		return fmt.Errorf("%s does not support lookbacks", definition.Type)
+	}
+	return validateDiscrete(definition)
+}
+
+func validateEventFeature(definition *FeatureDefinition) error {
+	if definition.Event == "" {
+		return fmt.Errorf("%s requires an event", definition.Type)
+	}
+	if definition.Hours > 0 || definition.LagDays > 0 || definition.Window > 0 {
+		return fmt.Errorf("%s only supports days", definition.Type)
+	}
+	return validateDiscrete(definition)
+}
+
+func validateDiscrete(definition *FeatureDefinition) error {
+	if definition.Normalization != "" && definition.Normalization != normalizationRaw {
+		return fmt.Errorf("%s values cannot be normalized", definition.Type)
+	}
+	return nil
+}
+
+func getTradingDayOfMonth(definition *FeatureDefinition, context *featureContext) *float64 {
+	date := getDateFromTime(context.timestamp)
+	firstDay := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location())
+	day := float64(context.calendar.countTradingDays(firstDay, date.AddDate(0, 0, 1)))
+	return &day
+}
+
+func getTradingDaysToMonthEnd(definition *FeatureDefinition, context *featureContext) *float64 {
+	date := getDateFromTime(context.timestamp)
+	nextMonth := time.Date(date.Year(), date.Month() + 1, 1, 0, 0, 0, 0, date.Location())
+	days := float64(context.calendar.countTradingDays(date.AddDate(0, 0, 1), nextMonth))
+	return &days
+}
+
+// Trading days until the last trading day of the selected contract, which requires a lastTrade rule
+func getDaysToExpiry(definition *FeatureDefinition, context *featureContext) *float64 {
+	if context.asset.LastTrade == nil {
+		return nil
+	}
+	date := getDateFromTime(context.timestamp)
+	expiry := context.asset.LastTrade.getDate(context.series.symbol, context.calendar)
+	if expiry.Before(date) {
+		return nil
+	}
+	days := float64(context.calendar.countTradingDays(date.AddDate(0, 0, 1), expiry.AddDate(0, 0, 1)))
+	return &days
+}
+
+// Flags sessions with the event scheduled within the next definition.Days trading days, since events are known in advance
+func getEventFlag(definition *FeatureDefinition, context *featureContext) *float64 {
+	event := events[definition.Event]
+	date := getDateFromTime(context.timestamp)
+	utcDate := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	if utcDate.Before(event.first) || utcDate.After(event.last) {
+		return nil
+	}
+	flag := 0.0
+	for i := 0; i <= definition.Days; i++ {
+		eventDate := context.calendar.addTradingDays(date, i)
+		_, exists := event.dates[time.Date(eventDate.Year(), eventDate.Month(), eventDate.Day(), 0, 0, 0, 0, time.UTC)]
+		if exists {
+			flag = 1.0
+			break
+		}
+	}
+	return &flag
+}
\ No newline at end of file
@@ -0,0 +1,397 @@
+package sibylla
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+const exportDefaultPath = "export"
+const exportJoinedName = "joined"
+const exportBatchSize = 1024
+
+const (
+	exportFormatCsv = "csv"
+	exportFormatJsonl = "jsonl"
+	exportFormatParquet = "parquet"
+)
+
+type exportTable struct {
+	keyName string
+	keyIsDate bool
+	columns []exportColumn
+	rows []exportRow
+}
+
+type exportColumn struct {
+	name string
+	integer bool
+}
+
+type exportRow struct {
+	key time.Time
+	// NaN if missing
+	values []float64
+}
+
+func Export(symbols string, format string, from string, to string, join bool) {
+	loadConfiguration()
+	if !slices.Contains([]string{exportFormatCsv, exportFormatJsonl, exportFormatParquet}, format) {
+		log.Fatalf("Unknown export format \"%s\"", format)
+	}
+	dateMin := SerializableDate{}
+	if from != "" {
+		dateMin.Time = getDate(from)
+	}
+	dateMax := SerializableDate{
+		Time: time.Date(9999, time.January, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if to != "" {
+		dateMax.Time = getDate(to)
+	}
+	symbolList := strings.Split(symbols, ",")
+	assetPaths := getAssetPaths(symbolList)
+	if len(assetPaths) != len(symbolList) {
+		log.Fatalf("Unable to find all of the assets %s", symbols)
+	}
+	start := time.Now()
+	archives := parallelMap(assetPaths, func (assetPath assetPath) Archive {
+		archive := readArchiveRange(assetPath.path, dateMin, dateMax)
+		archive.alignColumns()
+		archive.Symbol = assetPath.asset.Symbol
+		return archive
+	})
+	path := exportDefaultPath
+	if configuration.ExportPath != "" {
+		path = configuration.ExportPath
+	}
+	err := os.MkdirAll(path, 0755)
+	if err != nil {
+		log.Fatalf("Failed to create export directory (%s): %v", path, err)
+	}
+	dailyTables := []exportTable{}
+	intradayTables := []exportTable{}
+	for _, archive := range archives {
+		dailyTables = append(dailyTables, getDailyExportTable(&archive, dateMin, dateMax))
+		intradayTables = append(intradayTables, getIntradayExportTable(&archive, dateMin, dateMax))
+	}
+	if join && len(archives) > 1 {
+		prefixes := []string{}
+		for _, archive := range archives {
+			prefixes = append(prefixes, archive.Symbol)
+		}
+		dailyTable := joinExportTables(dailyTables, prefixes)
+		intradayTable := joinExportTables(intradayTables, prefixes)
+		dailyTable.write(getExportPath(path, exportJoinedName, "daily", format), format)
+		intradayTable.write(getExportPath(path, exportJoinedName, "intraday", format), format)
+	} else {
+		for i, archive := range archives {
+			dailyTables[i].write(getExportPath(path, archive.Symbol, "daily", format), format)
+			intradayTables[i].write(getExportPath(path, archive.Symbol, "intraday", format), format)
+		}
+	}
+	delta := time.Since(start)
+	fmt.Printf("Exported %d archives to %s in %.2f s\n", len(archives), path, delta.Seconds())
+}
+
+func getExportPath(directory string, name string, table string, format string) string {
+	fileName := fmt.Sprintf("%s.%s.%s", name, table, format)
+	return filepath.Join(directory, fileName)
+}
+
+func getDailyExportTable(archive *Archive, dateMin SerializableDate, dateMax SerializableDate) exportTable {
+	table := exportTable{
+		keyName: "date",
+		keyIsDate: true,
+		columns: []exportColumn{
+			{name: "close"},
+		},
+	}
+	for _, record := range archive.DailyRecords {
+		isValid, breakLoop := isValidDate(record.Date, dateMin, dateMax)
+		if breakLoop {
+			break
+		} else if !isValid {
+			continue
+		}
+		row := exportRow{
+			key: record.Date,
+			values: []float64{record.Close},
+		}
+		table.rows = append(table.rows, row)
+	}
+	return table
+}
+
+func getIntradayExportTable(archive *Archive, dateMin SerializableDate, dateMax SerializableDate) exportTable {
+	table := exportTable{
+		keyName: "timestamp",
+	}
+	for _, feature := range archive.Features {
+		column := exportColumn{
+			name: feature,
+		}
+		table.columns = append(table.columns, column)
+	}
+	for _, holdingTime := range archive.HoldingTimes {
+		name := getReturnsName(holdingTime)
+		for _, suffix := range []string{"High", "Low", "Close1", "Close2"} {
+			column := exportColumn{
+				name: name + suffix,
+				integer: true,
+			}
+			table.columns = append(table.columns, column)
+		}
+	}
+	for _, record := range archive.IntradayRecords {
+		isValid, breakLoop := isValidDate(record.Timestamp, dateMin, dateMax)
+		if breakLoop {
+			break
+		} else if !isValid {
+			continue
+		}
+		values := make([]float64, 0, len(table.columns))
+		values = append(values, record.Features...)
+		for _, returns := range record.Returns {
+			if returns.Valid {
+				values = append(values, float64(returns.High), float64(returns.Low), float64(returns.Close1), float64(returns.Close2))
+			} else {
+				nan := math.NaN()
+				values = append(values, nan, nan, nan, nan)
+			}
+		}
+		row := exportRow{
+			key: record.Timestamp,
+			values: values,
+		}
+		table.rows = append(table.rows, row)
+	}
+	return table
+}
+
+// Outer join on the key column, values of assets without a row at that key are missing
+func joinExportTables(tables []exportTable, prefixes []string) exportTable {
+	joined := exportTable{
+		keyName: tables[0].keyName,
+		keyIsDate: tables[0].keyIsDate,
+	}
+	offsets := []int{}
+	keys := []time.Time{}
+	for i, table := range tables {
+		offsets = append(offsets, len(joined.columns))
+		for _, column := range table.columns {
+			column.name = fmt.Sprintf("%s.%s", prefixes[i], column.name)
+			joined.columns = append(joined.columns, column)
+		}
+		for _, row := range table.rows {
+			keys = append(keys, row.key)
+		}
+	}
+	slices.SortFunc(keys, func (a, b time.Time) int {
+		return a.Compare(b)
+	})
+	keys = slices.CompactFunc(keys, func (a, b time.Time) bool {
+		return a.Equal(b)
+	})
+	rowMap := map[time.Time]int{}
+	for i, key := range keys {
+		values := make([]float64, len(joined.columns))
+		for j := range values {
+			values[j] = math.NaN()
+		}
+		row := exportRow{
+			key: key,
+			values: values,
+		}
+		joined.rows = append(joined.rows, row)
+		rowMap[key] = i
+	}
+	for i, table := range tables {
+		for _, row := range table.rows {
+			index := rowMap[row.key]
+			copy(joined.rows[index].values[offsets[i]:], row.values)
+		}
+	}
+	return joined
+}
+
+func (t *exportTable) write(path string, format string) {
+	file, err := os.Create(path)
+	if err != nil {
+		log.Fatalf("Failed to create export file (%s): %v", path, err)
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+	switch format {
+	case exportFormatCsv:
+		err = t.writeCsv(writer)
+	case exportFormatJsonl:
+		err = t.writeJsonl(writer)
+	case exportFormatParquet:
+		err = t.writeParquet(writer)
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		log.Fatalf("Failed to write export file (%s): %v", path, err)
+	}
+	fmt.Printf("Wrote %d rows to %s\n", len(t.rows), path)
+}
+
+func (t *exportTable) getKeyString(key time.Time) string {
+	if t.keyIsDate {
+		return getDateString(key)
+	}
+	return key.Format(time.RFC3339)
+}
+
+func (t *exportTable) getColumnNames() []string {
+	names := []string{t.keyName}
+	for _, column := range t.columns {
+		names = append(names, column.name)
+	}
+	return names
+}
+
+func (c *exportColumn) format(value float64) string {
+	if c.integer {
+		return strconv.FormatInt(int64(value), 10)
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func (t *exportTable) writeCsv(writer *bufio.Writer) error {
+	csvWriter := csv.NewWriter(writer)
+	err := csvWriter.Write(t.getColumnNames())
+	if err != nil {
+		return err
+	}
+	for _, row := range t.rows {
+		record := []string{t.getKeyString(row.key)}
+		for i, value := range row.values {
+			valueString := ""
+			if !math.IsNaN(value) {
+				valueString = t.columns[i].format(value)
+			}
+			record = append(record, valueString)
+		}
+		err = csvWriter.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+func (t *exportTable) writeJsonl(writer *bufio.Writer) error {
+	// Objects are serialized manually to preserve the column order
+	keys := []string{}
+	for _, name := range t.getColumnNames() {
+		key, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, string(key))
+	}
+	for _, row := range t.rows {
+		fields := []string{
+			fmt.Sprintf("%s:\"%s\"", keys[0], t.getKeyString(row.key)),
+		}
+		for i, value := range row.values {
+			valueString := "null"
+			if !math.IsNaN(value) && !math.IsInf(value, 0) {
+				valueString = t.columns[i].format(value)
+			}
+			fields = append(fields, fmt.Sprintf("%s:%s", keys[i + 1], valueString))
+		}
+		line := fmt.Sprintf("{%s}\n", strings.Join(fields, ","))
+		_, err := writer.WriteString(line)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *exportTable) writeParquet(writer *bufio.Writer) error {
+	group := parquet.Group{}
+	if t.keyIsDate {
+		group[t.keyName] = parquet.Date()
+	} else {
+		group[t.keyName] = parquet.Timestamp(parquet.Millisecond)
+	}
+	for _, column := range t.columns {
+		if column.integer {
+			group[column.name] = parquet.Optional(parquet.Int(32))
+		} else {
+			group[column.name] = parquet.Optional(parquet.Leaf(parquet.DoubleType))
+		}
+	}
+	schema := parquet.NewSchema("sibylla", group)
+	// Group nodes are sorted by name so the column indexes have to be looked up
+	getColumnIndex := func (name string) int {
+		leaf, exists := schema.Lookup(name)
+		if !exists {
+			log.Fatalf("Unable to find Parquet column %s", name)
+		}
+		return leaf.ColumnIndex
+	}
+	keyIndex := getColumnIndex(t.keyName)
+	columnIndexes := []int{}
+	for _, column := range t.columns {
+		columnIndexes = append(columnIndexes, getColumnIndex(column.name))
+	}
+	parquetWriter := parquet.NewWriter(writer, schema)
+	rows := []parquet.Row{}
+	flush := func () error {
+		_, err := parquetWriter.WriteRows(rows)
+		rows = rows[:0]
+		return err
+	}
+	for _, row := range t.rows {
+		parquetRow := make(parquet.Row, len(t.columns) + 1)
+		var key parquet.Value
+		if t.keyIsDate {
+			days := row.key.Unix() / (hoursPerDay * 60 * 60)
+			key = parquet.Int32Value(int32(days))
+		} else {
+			key = parquet.Int64Value(row.key.UnixMilli())
+		}
+		parquetRow[keyIndex] = key.Level(0, 0, keyIndex)
+		for i, value := range row.values {
+			index := columnIndexes[i]
+			if math.IsNaN(value) {
+				parquetRow[index] = parquet.NullValue().Level(0, 0, index)
+			} else if t.columns[i].integer {
+				parquetRow[index] = parquet.Int32Value(int32(value)).Level(0, 1, index)
+			} else {
+				parquetRow[index] = parquet.DoubleValue(value).Level(0, 1, index)
+			}
+		}
+		rows = append(rows, parquetRow)
+		if len(rows) >= exportBatchSize {
+			err := flush()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	err := flush()
+	if err != nil {
+		return err
+	}
+	return parquetWriter.Close()
+}
\ No newline at end of file
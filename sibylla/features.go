@@ -14,7 +14,8 @@ const combinedFeatureLimit = 20
 
 type featureStats struct {
 	name string
-	counts [2]int
+	// Occurrences of the feature by condition index
+	counts []int
 }
 
 type combinedFeatureStats struct {
@@ -32,11 +33,12 @@ func analyzeFeatureFrequency(assetResults map[string][]backtestData, miningConfi
 	if miningConfig.SeasonalityMode {
 		return nil
 	}
-	accessors := getFeatureAccessors()
+	accessors := getMiningFeatureAccessors()
 	features := []featureStats{}
 	for _, accessor := range accessors {
 		feature := featureStats{
 			name: accessor.name,
+			counts: make([]int, miningConfig.getMaxConditions()),
 		}
 		features = append(features, feature)
 	}
@@ -74,15 +76,19 @@ func analyzeFeatureFrequency(assetResults map[string][]backtestData, miningConfi
 				}
 				features[index].counts[featureIndex]++
 			}
-			index := slices.IndexFunc(combinedFeatures, func (c combinedFeatureStats) bool {
-				return c.names[0] == result.conditions[0].feature.name &&
-					c.names[1] == result.conditions[1].feature.name
-			})
-			if index == -1 {
-				continue
+			for j, parameter1 := range result.conditions {
+				for _, parameter2 := range result.conditions[j + 1:] {
+					index := slices.IndexFunc(combinedFeatures, func (c combinedFeatureStats) bool {
+						return c.names[0] == parameter1.feature.name &&
+							c.names[1] == parameter2.feature.name
+					})
+					if index == -1 {
+						continue
+					}
+					combinedFeatures[index].count++
+					combinedFeaturesTotal++
+				}
 			}
-			combinedFeatures[index].count++
-			combinedFeaturesTotal++
 		}
 	}
 	analysis := featureAnalysis{
@@ -100,7 +106,7 @@ func printFeatureFrequency(analysis featureAnalysis, miningConfig DataMiningConf
 	features := analysis.features
 	combinedFeatures := analysis.combinedFeatures
 	fmt.Println("")
-	for featureIndex := 0; featureIndex < 2; featureIndex++ {
+	for featureIndex := range features[0].counts {
 		sortedFeatures := make([]featureStats, len(features))
 		copy(sortedFeatures, features)
 		slices.SortFunc(sortedFeatures, func (a, b featureStats) int {
@@ -155,7 +161,10 @@ func getFeatureModel(analysis *featureAnalysis) *FeatureAnalysis {
 			featuresTotal += f.counts[featureIndex]
 		}
 		for i, f := range features {
-			frequency := float64(f.counts[featureIndex]) / float64(featuresTotal)
+			frequency := 0.0
+			if featuresTotal > 0 {
+				frequency = float64(f.counts[featureIndex]) / float64(featuresTotal)
+			}
 			frequencies := &featureFrequencies[i].Frequencies
 			*frequencies = append(*frequencies, frequency)
 		}
@@ -3,10 +3,10 @@ package sibylla
 import (
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
 	"sort"
-	"strconv"
 	"time"
 )
 
@@ -36,56 +36,85 @@ type globexTimeKey struct {
 type readDailyRecordsResult struct {
 	openIntRecords []openInterestRecords
 	dailyCloses dailyCloseMap
+	dailyOpenInterest dailyOpenInterestMap
 	includedRecords int
 	excludedRecords int
 }
 
 type intradayRecord struct {
+	open float64
 	high float64
 	low float64
 	close float64
+	volume float64
 }
 
 type openInterestMap map[time.Time][]dailyRecord
 type dailyRecordMap map[time.Time]dailyRecord
 type dailyCloseMap map[globexDateKey]float64
+type dailyOpenInterestMap map[globexDateKey]int
 type intradayRecordsMap map[globexTimeKey]intradayRecord
 
-func Generate(symbol *string) {
+func Generate(symbol *string, update bool) {
 	loadConfiguration()
 	start := time.Now()
 	if symbol == nil {
 		parallelForEach(*assets, func (asset Asset) {
-			generateArchives(asset, false)
+			generateArchives(asset, false, update)
 		})
+		updateCrossSectionalFeatures(nil)
 	} else {
-		generateSingleArchive(*symbol)
+		generateSingleArchive(*symbol, update)
+		updateCrossSectionalFeatures([]string{*symbol})
 	}
 	delta := time.Since(start)
 	fmt.Printf("Generated archives in %.2f s\n", delta.Seconds())
 }
 
-func generateSingleArchive(symbol string) {
+func generateSingleArchive(symbol string, update bool) {
 	for _, asset := range *assets {
 		if asset.Symbol == symbol {
-			generateArchives(asset, true)
+			generateArchives(asset, true, update)
 			return
 		}
 	}
 	log.Fatalf("Unable to find an asset matching symbol %s", symbol)
 }
 
-func generateArchives(asset Asset, forceOverwrite bool) {
+func generateArchives(asset Asset, forceOverwrite bool, update bool) {
 	firstArchivePath := getArchivePath(asset.Symbol, 1)
-	if !forceOverwrite && !configuration.OverwriteArchives {
+	if !update && !forceOverwrite && !configuration.OverwriteArchives {
 		_, err := os.Stat(firstArchivePath)
 		if !os.IsNotExist(err) {
 			fmt.Printf("[%s] Archive already exists, skipping: %s\n", asset.Symbol, firstArchivePath)
 			return
 		}
 	}
-	dailyRecordsResult := readDailyRecords(asset)
-	intradayCloses := readIntradayRecords(asset)
+	fLimit := 1
+	if asset.FRecords != nil {
+		fLimit = *asset.FRecords
+	}
+	var updates []*archiveUpdate
+	var since time.Time
+	var previousSources []SourceChecksum
+	if update {
+		updates, since, previousSources = getArchiveUpdates(asset, fLimit)
+	}
+	index := newSourceIndex(since, previousSources)
+	source := asset.getDataSource()
+	indexed, isIndexed := source.(indexedSource)
+	if isIndexed {
+		source = indexed.withIndex(index)
+	}
+	dailyRecordsResult := readDailyRecords(asset, source, since)
+	intradayCloses := readIntradayRecords(asset, source, since)
+	if len(intradayCloses) == 0 {
+		// Synthetic assets whose values are never valid have no bars at all
+		fmt.Printf("[%s] No intraday bars available, skipping asset\n", asset.Symbol)
+		return
+	}
+	dailyRanges := getDailyRanges(intradayCloses)
+	sources := getSourceChecksums(source, index)
 	intradayTimestampsMap := map[time.Time]struct{}{}
 	for key := range intradayCloses {
 		intradayTimestampsMap[key.timestamp] = struct{}{}
@@ -100,18 +129,22 @@ func generateArchives(asset Asset, forceOverwrite bool) {
 	totalRecords := dailyRecordsResult.includedRecords + dailyRecordsResult.excludedRecords
 	exclusionRatio := float64(dailyRecordsResult.excludedRecords) / float64(totalRecords) * 100.0
 	fmt.Printf("[%s] Excluded %.2f%% of records\n", asset.Symbol, exclusionRatio)
-	fLimit := 1
-	if asset.FRecords != nil {
-		fLimit = *asset.FRecords
-	}
 	for fNumber := 1; fNumber <= fLimit; fNumber++ {
+		var fUpdate *archiveUpdate
+		if updates != nil {
+			fUpdate = updates[fNumber - 1]
+		}
 		generateFRecords(
 			fNumber,
 			dailyRecordsResult.openIntRecords,
 			dailyRecordsResult.dailyCloses,
+			dailyRecordsResult.dailyOpenInterest,
 			intradayCloses,
+			dailyRanges,
 			intradayTimestamps,
 			asset,
+			sources,
+			fUpdate,
 		)
 	}
 }
@@ -120,57 +153,113 @@ func generateFRecords(
 	fNumber int,
 	openIntRecords []openInterestRecords,
 	dailyCloses dailyCloseMap,
+	dailyOpenInterest dailyOpenInterestMap,
 	intradayCloses intradayRecordsMap,
+	dailyRanges dailyRangeMap,
 	intradayTimestamps []time.Time,
 	asset Asset,
+	sources []SourceChecksum,
+	update *archiveUpdate,
 ) {
 	path := getArchivePath(asset.Symbol, fNumber)
-	dailyMap := dailyRecordMap{}
+	dailyMap := getDailyRecordMap(fNumber, openIntRecords)
+	rolls := getRolls(openIntRecords, dailyMap)
+	adjustment := newPriceAdjustment(asset.getAdjustment(), rolls, dailyCloses, &asset)
 	dailyRecords := []DailyRecord{}
 	for _, datedRecords := range openIntRecords {
 		date := datedRecords.date
-		fRecord := getFRecord(fNumber, date, datedRecords.records)
-		if fRecord == nil {
+		fRecord, exists := dailyMap[date]
+		if !exists {
 			continue
 		}
-		dailyMap[date] = *fRecord
+		close := fRecord.close
+		if adjustment != nil {
+			close = adjustment.adjust(date, close)
+		}
 		dailyRecord := DailyRecord{
 			Date: date,
-			Close: fRecord.close,
+			Close: close,
 		}
 		dailyRecords = append(dailyRecords, dailyRecord)
 	}
+	location := asset.getLocation()
 	archive := Archive{
 		Symbol: asset.Symbol,
+		Timezone: asset.Timezone,
+		Features: getFeatureNames(),
+		HoldingTimes: getHoldingTimes(),
 		DailyRecords: dailyRecords,
+		Rolls: rolls,
+	}
+	archive.Bars = getBarSeries(intradayTimestamps, dailyMap, intradayCloses, location, &asset)
+	if update != nil {
+		intradayTimestamps = update.filterTimestamps(intradayTimestamps, location)
 	}
 	for _, timestamp := range intradayTimestamps {
 		processIntradayTimestamp(
 			timestamp,
 			dailyMap,
 			dailyCloses,
+			dailyOpenInterest,
 			intradayCloses,
+			dailyRanges,
+			adjustment,
+			location,
 			&asset,
 			&archive,
 		)
 	}
-	if configuration.QuantileTransform {
-		archive.IntradayRecords = quantileTransform(configuration.QuantileBufferSize, configuration.QuantileStride, archive.IntradayRecords)
+	if len(archive.IntradayRecords) == 0 {
+		fmt.Printf("[%s] No intraday records were generated, skipping %s\n", asset.Symbol, path)
+		return
 	}
-	sizeBytes := writeArchive(path, &archive)
+	if update != nil {
+		update.merge(&archive)
+	} else if configuration.QuantileTransform {
+		archive.IntradayRecords = quantileTransform(configuration.QuantileBufferSize, configuration.QuantileStride, 0, archive.IntradayRecords)
+	}
+	header := newArchiveHeader(&asset, fNumber, sources)
+	sizeBytes := writeArchive(path, &header, &archive)
 	sizeMibibytes := float64(sizeBytes) / 1024.0 / 1024.0
 	fmt.Printf("[%s] Wrote archive to %s (%.1f MiB)\n", asset.Symbol, path, sizeMibibytes)
+	if configuration.ColumnarArchives {
+		columnarPath := getColumnarPath(path)
+		sizeBytes = writeColumnarArchive(columnarPath, &header, &archive)
+		sizeMibibytes = float64(sizeBytes) / 1024.0 / 1024.0
+		fmt.Printf("[%s] Wrote columnar archive to %s (%.1f MiB)\n", asset.Symbol, columnarPath, sizeMibibytes)
+	}
+}
+
+func getDailyRecordMap(fNumber int, openIntRecords []openInterestRecords) dailyRecordMap {
+	dailyMap := dailyRecordMap{}
+	for _, datedRecords := range openIntRecords {
+		date := datedRecords.date
+		fRecord := getFRecord(fNumber, date, datedRecords.records)
+		if fRecord == nil {
+			continue
+		}
+		dailyMap[date] = *fRecord
+	}
+	return dailyMap
 }
 
 func processIntradayTimestamp(
 	timestamp time.Time,
 	dailyRecords dailyRecordMap,
 	dailyCloses dailyCloseMap,
+	dailyOpenInterest dailyOpenInterestMap,
 	intradayRecords intradayRecordsMap,
+	dailyRanges dailyRangeMap,
+	adjustment *priceAdjustment,
+	location *time.Location,
 	asset *Asset,
 	archive *Archive,
 ) {
-	date := getDateFromTime(timestamp)
+	calendar := asset.getCalendar()
+	if !calendar.isTradingTime(timestamp) {
+		return
+	}
+	date := calendar.getSessionDate(timestamp)
 	record, exists := dailyRecords[date]
 	if !exists {
 		return
@@ -181,47 +270,46 @@ func processIntradayTimestamp(
 	if !exists {
 		return
 	}
-	momentumHelper := func (offsetDays, lagDays, offsetHours int) *float64 {
-		return getMomentum(
-			offsetDays,
-			lagDays,
-			offsetHours,
-			timestamp,
-			intradayRecord,
-			symbol,
-			dailyCloses,
-			intradayRecords,
-		)
-	}
-	returnsHelper := func (offsetDays, offsetHours int) *ReturnsRecord {
-		return getReturns(
+	context := featureContext{
+		timestamp: timestamp,
+		record: intradayRecord,
+		series: priceSeries{
+			symbol: symbol,
+			dailyMap: dailyRecords,
+			adjustment: adjustment,
+			anchor: timestamp,
+		},
+		dailyCloses: dailyCloses,
+		dailyOpenInterest: dailyOpenInterest,
+		intradayRecords: intradayRecords,
+		dailyRanges: dailyRanges,
+		calendar: calendar,
+		asset: asset,
+	}
+	holdingTimes := getHoldingTimes()
+	returns := make([]ReturnsRecord, len(holdingTimes))
+	for i, holdingTime := range holdingTimes {
+		offsetDays := holdingTime / hoursPerDay
+		offsetHours := holdingTime % hoursPerDay
+		returnsRecord := getReturns(
 			offsetDays,
 			offsetHours,
 			timestamp,
 			intradayRecord,
 			symbol,
 			intradayRecords,
+			calendar,
 			asset,
 		)
+		if returnsRecord != nil {
+			returns[i] = *returnsRecord
+		}
 	}
-	closeTimestamp := timestamp.Add(time.Hour)
+	closeTimestamp := getUTCTime(getCloseTimestamp(timestamp), location)
 	features := FeatureRecord{
 		Timestamp: closeTimestamp,
-		Momentum1D: momentumHelper(1, 0, 0),
-		Momentum1DLag: momentumHelper(2, 1, 0),
-		Momentum2D: momentumHelper(2, 0, 0),
-		Momentum5D: momentumHelper(5, 0, 0),
-		Momentum10D: momentumHelper(10, 0, 0),
-		Momentum1H: momentumHelper(0, 0, 1),
-		Momentum2H: momentumHelper(0, 0, 2),
-		Momentum4H: momentumHelper(0, 0, 4),
-		Momentum8H: momentumHelper(0, 0, 8),
-		Returns4H: returnsHelper(0, 4),
-		Returns8H: returnsHelper(0, 8),
-		Returns16H: returnsHelper(0, 16),
-		Returns24H: returnsHelper(1, 0),
-		Returns48H: returnsHelper(2, 0),
-		Returns72H: returnsHelper(3, 0),
+		Features: getFeatureValues(&context),
+		Returns: returns,
 	}
 	if features.includeRecord() {
 		archive.IntradayRecords = append(archive.IntradayRecords, features)
@@ -232,38 +320,45 @@ func getMomentum(
 	offsetDays int,
 	lagDays int,
 	offsetHours int,
-	timestamp time.Time,
-	record intradayRecord,
-	symbol GlobexCode,
-	dailyCloses dailyCloseMap,
-	intradayRecords intradayRecordsMap,
+	context *featureContext,
 ) *float64 {
-	close := record.close
-	offsetTimestamp := getAdjustedTimestamp(-offsetDays, -offsetHours, timestamp)
+	timestamp := context.timestamp
+	series := context.series
+	calendar := context.calendar
+	close := series.adjust(timestamp, context.record.close)
+	offsetTimestamp := getAdjustedTimestamp(-offsetDays, -offsetHours, timestamp, calendar)
+	offsetSymbol, exists := series.getSymbol(offsetTimestamp)
+	if !exists {
+		return nil
+	}
 	var offsetClose float64
 	if offsetHours == 0 {
-		key := getGlobexDateKey(symbol, offsetTimestamp)
-		dailyClose, exists := dailyCloses[key]
+		key := getGlobexDateKey(offsetSymbol, offsetTimestamp)
+		dailyClose, exists := context.dailyCloses[key]
 		if !exists {
 			return nil
 		}
-		offsetClose = dailyClose
+		offsetClose = series.adjust(offsetTimestamp, dailyClose)
 	} else {
-		key := getGlobexTimeKey(symbol, offsetTimestamp)
-		offsetRecord, exists := intradayRecords[key]
+		key := getGlobexTimeKey(offsetSymbol, offsetTimestamp)
+		offsetRecord, exists := context.intradayRecords[key]
 		if !exists {
 			return nil
 		}
-		offsetClose = offsetRecord.close
+		offsetClose = series.adjust(offsetTimestamp, offsetRecord.close)
 	}
 	if lagDays > 0 {
-		lagTimestamp := getAdjustedTimestamp(-lagDays, 0, timestamp)
-		key := getGlobexDateKey(symbol, lagTimestamp)
-		lagClose, exists := dailyCloses[key]
+		lagTimestamp := getAdjustedTimestamp(-lagDays, 0, timestamp, calendar)
+		lagSymbol, exists := series.getSymbol(lagTimestamp)
+		if !exists {
+			return nil
+		}
+		key := getGlobexDateKey(lagSymbol, lagTimestamp)
+		lagClose, exists := context.dailyCloses[key]
 		if !exists {
 			return nil
 		}
-		close = lagClose
+		close = series.adjust(lagTimestamp, lagClose)
 	}
 	momentum, valid := getRateOfChange(close, offsetClose)
 	if !valid {
@@ -279,9 +374,10 @@ func getReturns(
 	record intradayRecord,
 	symbol GlobexCode,
 	intradayRecords intradayRecordsMap,
+	calendar *tradingCalendar,
 	asset *Asset,
 ) *ReturnsRecord {
-	adjustedTimestamp := getAdjustedTimestamp(offsetDays, offsetHours, timestamp)
+	adjustedTimestamp := getAdjustedTimestamp(offsetDays, offsetHours, timestamp, calendar)
 	key := getGlobexTimeKey(symbol, adjustedTimestamp)
 	horizonRecord, exists := intradayRecords[key]
 	getTicks := func (value float64) int {
@@ -332,6 +428,7 @@ func getReturns(
 	highTicks := getTicks(*high)
 	lowTicks := getTicks(*low)
 	returnsRecord := ReturnsRecord{
+		Valid: true,
 		High: highTicks,
 		Low: lowTicks,
 		Close1: closeTicks1,
@@ -340,30 +437,18 @@ func getReturns(
 	return &returnsRecord
 }
 
-func getAdjustedTimestamp(offsetDays int, offsetHours int, timestamp time.Time) time.Time {
-	adjustedTimestamp := timestamp
-	direction := 1
-	if offsetDays < 0 {
-		offsetDays = -offsetDays
-		direction = -1
-	}
-	for range offsetDays {
-		adjustedTimestamp = adjustedTimestamp.AddDate(0, 0, direction)
-		for {
-			weekday := adjustedTimestamp.Weekday()
-			if weekday == time.Saturday || weekday == time.Sunday {
-				adjustedTimestamp = adjustedTimestamp.AddDate(0, 0, direction)
-			} else {
-				break
-			}
-		}
-	}
+func getAdjustedTimestamp(offsetDays int, offsetHours int, timestamp time.Time, calendar *tradingCalendar) time.Time {
+	adjustedTimestamp := calendar.addTradingDays(timestamp, offsetDays)
 	if offsetHours != 0 {
 		adjustedTimestamp = adjustedTimestamp.Add(time.Duration(offsetHours) * time.Hour)
 	}
 	return adjustedTimestamp
 }
 
+func getCloseTimestamp(timestamp time.Time) time.Time {
+	return timestamp.Add(time.Hour)
+}
+
 func getFRecord(fNumber int, date time.Time, records []dailyRecord) *dailyRecord {
 	root := records[0].symbol.Root
 	index := fNumber - 1
@@ -374,51 +459,45 @@ func getFRecord(fNumber int, date time.Time, records []dailyRecord) *dailyRecord
 	return &records[index]
 }
 
-func readDailyRecords(asset Asset) readDailyRecordsResult {
-	path := getBarchartCsvPath(asset, "D1")
-	columns := []string{"symbol", "time", "close", "open_interest"}
+func readDailyRecords(asset Asset, source DataSource, since time.Time) readDailyRecordsResult {
 	openIntMap := openInterestMap{}
 	dailyCloses := dailyCloseMap{}
+	dailyOpenInterest := dailyOpenInterestMap{}
 	includedRecords := 0
 	excludedRecords := 0
-	callback := func(values []string) {
-		symbol, err := parseGlobex(values[0])
-		if err != nil {
-			log.Fatal(err)
+	source.readDailyRecords(func (date time.Time, record dailyRecord) {
+		if date.Before(since) {
+			// Record is already part of the archive being updated
+			return
 		}
-		date := getDate(values[1])
 		if date.Before(configuration.CutoffDate.Time) {
 			// Record is too old, skip it
 			excludedRecords += 1
 			return
 		}
-		if !asset.includeRecord(date, symbol) {
+		if !asset.includeRecord(date, record.symbol) {
 			// The contract filter excludes the record, skip it
 			excludedRecords += 1
 			return
 		}
-		close := parseFloat(values[2])
-		openInterestString := values[3]
-		openInterest, err := strconv.Atoi(openInterestString)
-		if err != nil {
-			log.Fatalf("Failed to parse open interest value \"%s\" in CSV file (%s): %v", openInterestString, path, err)
-		}
-		openIntRecord := dailyRecord{
-			symbol: symbol,
-			close: close,
-			openInterest: openInterest,
-		}
-		openIntMap[date] = append(openIntMap[date], openIntRecord)
-		key := getGlobexDateKey(symbol, date)
-		dailyCloses[key] = close
+		openIntMap[date] = append(openIntMap[date], record)
+		key := getGlobexDateKey(record.symbol, date)
+		dailyCloses[key] = record.close
+		dailyOpenInterest[key] = record.openInterest
 		includedRecords += 1
-	}
-	readCsv(path, columns, callback)
+	})
 	openIntRecords := []openInterestRecords{}
+	rollDates := rollDateMap{}
+	ranker, customRanking := source.(contractRanker)
 	for date, records := range openIntMap {
-		sort.Slice(records, func (i, j int) bool {
-			return records[i].openInterest > records[j].openInterest
-		})
+		if customRanking {
+			records = ranker.rankContracts(date, records)
+		} else {
+			records = asset.rankContracts(date, records, rollDates)
+		}
+		if len(records) == 0 {
+			continue
+		}
 		datedRecords := openInterestRecords{
 			date: date,
 			records: records,
@@ -431,75 +510,37 @@ func readDailyRecords(asset Asset) readDailyRecordsResult {
 	result := readDailyRecordsResult {
 		openIntRecords: openIntRecords,
 		dailyCloses: dailyCloses,
+		dailyOpenInterest: dailyOpenInterest,
 		includedRecords: includedRecords,
 		excludedRecords: excludedRecords,
 	}
 	return result
 }
 
-func readIntradayRecords(asset Asset) intradayRecordsMap {
-	path := getBarchartCsvPath(asset, "H1")
-	columns := []string{"symbol", "time", "high", "low", "close"}
+func readIntradayRecords(asset Asset, source DataSource, since time.Time) intradayRecordsMap {
 	recordsMap := intradayRecordsMap{}
-	callback := func(values []string) {
-		symbol, err := parseGlobex(values[0])
-		if err != nil {
-			log.Fatal(err)
-		}
-		timestamp := getTime(values[1])
-		if timestamp.Before(configuration.CutoffDate.Time) {
+	source.readIntradayRecords(func (key globexTimeKey, record intradayRecord) {
+		if key.timestamp.Before(configuration.CutoffDate.Time) || key.timestamp.Before(since) {
 			return
 		}
 		for _, excludedTime := range asset.ExcludeRecords {
-			if excludedTime.Time.Equal(timestamp) {
+			if excludedTime.Time.Equal(key.timestamp) {
 				return
 			}
 		}
-		high := parseFloat(values[2])
-		low := parseFloat(values[3])
-		close := parseFloat(values[4])
-		key := getGlobexTimeKey(symbol, timestamp)
-		recordsMap[key] = intradayRecord{
-			high: high,
-			low: low,
-			close: close,
-		}
-	}
-	readCsv(path, columns, callback)
+		recordsMap[key] = record
+	})
 	return recordsMap
 }
 
-func getBarchartCsvPath(asset Asset, suffix string) string {
-	symbol := asset.getBarchartSymbol()
-	filename := fmt.Sprintf("%s.%s.csv", symbol, suffix)
-	path := filepath.Join(configuration.BarchartPath, filename)
-	return path
-}
-
 func (f *FeatureRecord) includeRecord() bool {
-	features := []*float64{
-		f.Momentum1D,
-		f.Momentum1DLag,
-		f.Momentum2D,
-		f.Momentum5D,
-		f.Momentum10D,
-		f.Momentum1H,
-		f.Momentum2H,
-		f.Momentum4H,
-		f.Momentum8H,
-	}
-	for _, x := range features {
-		if x != nil {
+	for _, x := range f.Features {
+		if !math.IsNaN(x) {
 			return true
 		}
 	}
-	returns := []*ReturnsRecord{
-		f.Returns24H,
-		f.Returns48H,
-		f.Returns72H,
-	}
-	for _, x := range returns {
-		if x != nil {
+	for i, holdingTime := range getHoldingTimes() {
+		if holdingTime >= hoursPerDay && f.Returns[i].Valid {
 			return true
 		}
 	}
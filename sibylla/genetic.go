@@ -0,0 +1,436 @@
+package sibylla
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/cheggaaa/pb"
+)
+
+const geneticFitnessSharpe = "sharpe"
+const geneticFitnessMinSharpe = "minSharpe"
+const geneticFitnessRecentSharpe = "recentSharpe"
+const defaultGeneticMutationRate = 0.1
+const defaultGeneticSeed = 1
+const geneticTournamentSize = 3
+const geneticEliteRatio = 0.05
+const geneticRepairAttempts = 100
+
+type GeneticConfiguration struct {
+	Population int `yaml:"population"`
+	Generations int `yaml:"generations"`
+	Seeds []uint64 `yaml:"seeds"`
+	MutationRate *float64 `yaml:"mutationRate"`
+	Fitness string `yaml:"fitness"`
+}
+
+// Conditions are placed on the same grid as in the exhaustive search, the minimum is position * increment
+type geneticCondition struct {
+	asset int
+	feature int
+	position int
+}
+
+type geneticIndividual struct {
+	conditions []geneticCondition
+	side int
+	optimizeWeekdays bool
+	timeOfDay int
+	returns int
+	stopLoss int
+	fitness float64
+}
+
+type geneticSearch struct {
+	assetRecords []assetRecords
+	tradedAssets []int
+	accessors []featureAccessor
+	bounds [][]featureBounds
+	sides []PositionSide
+	optimizeWeekdaysModes []bool
+	timesOfDay []time.Duration
+	returnsAccessors []returnsAccessor
+	stopLossLimits []*float64
+	positions int
+	minConditions int
+	maxConditions int
+	miningConfig DataMiningConfiguration
+	fitness map[string]float64
+	results [][]backtestData
+}
+
+// Each seed evolves a population of its own, strategies evaluated by any of them are passed on to post-processing
+func executeGeneticSearch(assetRecords []assetRecords, miningConfig DataMiningConfiguration) [][]backtestData {
+	search := newGeneticSearch(assetRecords, miningConfig)
+	geneticConfig := miningConfig.Genetic
+	seeds := geneticConfig.getSeeds()
+	fmt.Printf("Evolving strategies (population %d, %d generations, %d seeds)\n", geneticConfig.Population, geneticConfig.Generations, len(seeds))
+	bar := pb.StartNew(len(seeds) * geneticConfig.Generations * geneticConfig.Population)
+	bar.Start()
+	bestIndividuals := []geneticIndividual{}
+	for _, seed := range seeds {
+		random := rand.New(rand.NewPCG(seed, seed))
+		population := []geneticIndividual{}
+		for range geneticConfig.Population {
+			population = append(population, search.newIndividual(random))
+		}
+		for generation := range geneticConfig.Generations {
+			search.evaluate(population)
+			bar.Add(len(population))
+			slices.SortFunc(population, func (a, b geneticIndividual) int {
+				return compareFloat64(b.fitness, a.fitness)
+			})
+			if generation < geneticConfig.Generations - 1 {
+				population = search.getNextGeneration(population, random)
+			}
+		}
+		bestIndividuals = append(bestIndividuals, population[0])
+	}
+	bar.Finish()
+	for i, individual := range bestIndividuals {
+		fmt.Printf("Best %s with seed %d: %.2f\n", geneticConfig.getFitness(), seeds[i], individual.fitness)
+	}
+	fmt.Printf("Evaluated %d distinct strategies\n", len(search.fitness))
+	return search.results
+}
+
+func newGeneticSearch(assetRecords []assetRecords, miningConfig DataMiningConfiguration) *geneticSearch {
+	accessors := getMiningFeatureAccessors()
+	tradedAssets := []int{}
+	for i, records := range assetRecords {
+		if !records.asset.FeaturesOnly && !slices.Contains(miningConfig.FeaturesOnly, records.asset.Symbol) {
+			tradedAssets = append(tradedAssets, i)
+		}
+	}
+	if len(tradedAssets) == 0 || len(accessors) == 0 {
+		log.Fatal("Genetic search requires at least one traded asset and one feature")
+	}
+	sides := []PositionSide{}
+	if miningConfig.EnableLong {
+		sides = append(sides, SideLong)
+	}
+	if miningConfig.EnableShort {
+		sides = append(sides, SideShort)
+	}
+	optimizeWeekdaysModes := []bool{false}
+	if miningConfig.OptimizeWeekdays {
+		optimizeWeekdaysModes = append(optimizeWeekdaysModes, true)
+	}
+	// Single feature strategies use the same condition twice, just like in the exhaustive search
+	minConditions := defaultMaxConditions
+	maxConditions := miningConfig.getMaxConditions()
+	if miningConfig.SingleFeature {
+		minConditions = 1
+		maxConditions = 1
+	}
+	if len(assetRecords) * len(accessors) < minConditions {
+		log.Fatal("Not enough features available for genetic search")
+	}
+	positions := int(math.Floor((conditionRangeLimit - miningConfig.Conditions.Range) / miningConfig.Conditions.Increment)) + 1
+	return &geneticSearch{
+		assetRecords: assetRecords,
+		tradedAssets: tradedAssets,
+		accessors: accessors,
+		bounds: getFeatureBounds(assetRecords, accessors),
+		sides: sides,
+		optimizeWeekdaysModes: optimizeWeekdaysModes,
+		timesOfDay: miningConfig.getTimesOfDay(),
+		returnsAccessors: miningConfig.getReturnsAccessors(),
+		stopLossLimits: getStopLossLimits(miningConfig),
+		positions: max(positions, 1),
+		minConditions: minConditions,
+		maxConditions: maxConditions,
+		miningConfig: miningConfig,
+		fitness: map[string]float64{},
+		results: [][]backtestData{},
+	}
+}
+
+func (s *geneticSearch) newIndividual(random *rand.Rand) geneticIndividual {
+	individual := geneticIndividual{
+		side: random.IntN(len(s.sides)),
+		optimizeWeekdays: s.optimizeWeekdaysModes[random.IntN(len(s.optimizeWeekdaysModes))],
+		timeOfDay: random.IntN(len(s.timesOfDay)),
+		returns: random.IntN(len(s.returnsAccessors)),
+		stopLoss: random.IntN(len(s.stopLossLimits)),
+	}
+	conditionCount := s.minConditions + random.IntN(s.maxConditions - s.minConditions + 1)
+	for i := range conditionCount {
+		individual.conditions = append(individual.conditions, s.newCondition(i == 0, random))
+	}
+	s.repair(&individual, random)
+	return individual
+}
+
+// The first condition determines the traded asset so it is restricted to assets that aren't featuresOnly
+func (s *geneticSearch) newCondition(first bool, random *rand.Rand) geneticCondition {
+	var asset int
+	if first {
+		asset = s.tradedAssets[random.IntN(len(s.tradedAssets))]
+	} else {
+		asset = random.IntN(len(s.assetRecords))
+	}
+	return geneticCondition{
+		asset: asset,
+		feature: random.IntN(len(s.accessors)),
+		position: random.IntN(s.positions),
+	}
+}
+
+// Strategies that have already been evaluated by an earlier generation or another seed are looked up rather than backtested again
+func (s *geneticSearch) evaluate(population []geneticIndividual) {
+	pending := []geneticIndividual{}
+	pendingKeys := map[string]struct{}{}
+	for _, individual := range population {
+		key := individual.getKey()
+		_, evaluated := s.fitness[key]
+		_, isPending := pendingKeys[key]
+		if !evaluated && !isPending {
+			pending = append(pending, individual)
+			pendingKeys[key] = struct{}{}
+		}
+	}
+	backtests := parallelMap(pending, func (individual geneticIndividual) backtestData {
+		return s.backtest(individual)
+	})
+	for i, backtest := range backtests {
+		s.fitness[pending[i].getKey()] = s.miningConfig.Genetic.getFitnessValue(backtest)
+		if backtest.enabled {
+			s.results = append(s.results, []backtestData{backtest})
+		}
+	}
+	for i := range population {
+		individual := &population[i]
+		individual.fitness = s.fitness[individual.getKey()]
+	}
+}
+
+func (s *geneticSearch) backtest(individual geneticIndividual) backtestData {
+	conditions := []strategyCondition{}
+	for _, gene := range individual.conditions {
+		records := s.assetRecords[gene.asset]
+		bounds := s.bounds[gene.asset][gene.feature]
+		minimum := float64(gene.position) * s.miningConfig.Conditions.Increment
+		maximum := minimum + s.miningConfig.Conditions.Range
+		condition := newDataMiningParameter(records, s.accessors[gene.feature], bounds.scale(minimum), bounds.scale(maximum))
+		conditions = append(conditions, condition)
+	}
+	if s.miningConfig.SingleFeature {
+		conditions = append(conditions, conditions[0])
+	}
+	task := dataMiningTask{
+		conditions: conditions,
+	}
+	backtest := newMiningBacktest(
+		task,
+		&conditions[0].asset,
+		s.sides[individual.side],
+		s.timesOfDay[individual.timeOfDay],
+		s.returnsAccessors[individual.returns],
+		s.stopLossLimits[individual.stopLoss],
+		individual.optimizeWeekdays,
+		s.miningConfig,
+	)
+	backtests := []backtestData{backtest}
+	runFeatureMiningBacktests(task, backtests, s.miningConfig)
+	return backtests[0]
+}
+
+// The fittest individuals are carried over unchanged, the rest of the generation is bred from parents chosen by tournament selection
+func (s *geneticSearch) getNextGeneration(population []geneticIndividual, random *rand.Rand) []geneticIndividual {
+	eliteCount := max(int(geneticEliteRatio * float64(len(population))), 1)
+	nextGeneration := []geneticIndividual{}
+	for _, individual := range population[:eliteCount] {
+		nextGeneration = append(nextGeneration, individual.clone())
+	}
+	for len(nextGeneration) < len(population) {
+		parent1 := s.selectParent(population, random)
+		parent2 := s.selectParent(population, random)
+		child := s.crossover(parent1, parent2, random)
+		s.mutate(&child, random)
+		s.repair(&child, random)
+		nextGeneration = append(nextGeneration, child)
+	}
+	return nextGeneration
+}
+
+func (s *geneticSearch) selectParent(population []geneticIndividual, random *rand.Rand) geneticIndividual {
+	winner := population[random.IntN(len(population))]
+	for range geneticTournamentSize - 1 {
+		contender := population[random.IntN(len(population))]
+		if contender.fitness > winner.fitness {
+			winner = contender
+		}
+	}
+	return winner
+}
+
+// Scalar genes are inherited from either parent, conditions are combined using a single cut
+func (s *geneticSearch) crossover(parent1, parent2 geneticIndividual, random *rand.Rand) geneticIndividual {
+	pick := func () geneticIndividual {
+		if random.IntN(2) == 0 {
+			return parent1
+		}
+		return parent2
+	}
+	child := geneticIndividual{
+		side: pick().side,
+		optimizeWeekdays: pick().optimizeWeekdays,
+		timeOfDay: pick().timeOfDay,
+		returns: pick().returns,
+		stopLoss: pick().stopLoss,
+	}
+	cut := 1 + random.IntN(min(len(parent1.conditions), len(parent2.conditions)))
+	child.conditions = append(slices.Clone(parent1.conditions[:cut]), parent2.conditions[cut:]...)
+	return child
+}
+
+func (s *geneticSearch) mutate(individual *geneticIndividual, random *rand.Rand) {
+	mutationRate := s.miningConfig.Genetic.getMutationRate()
+	mutates := func () bool {
+		return random.Float64() < mutationRate
+	}
+	for i := range individual.conditions {
+		condition := &individual.conditions[i]
+		if mutates() {
+			// Small shifts of the range are more useful than jumping to an arbitrary position
+			shift := 1 + random.IntN(2)
+			if random.IntN(2) == 0 {
+				shift = - shift
+			}
+			condition.position = min(max(condition.position + shift, 0), s.positions - 1)
+		}
+		if mutates() {
+			condition.feature = random.IntN(len(s.accessors))
+		}
+		if mutates() {
+			*condition = s.newCondition(i == 0, random)
+		}
+	}
+	if mutates() && len(individual.conditions) < s.maxConditions {
+		individual.conditions = append(individual.conditions, s.newCondition(false, random))
+	}
+	if mutates() && len(individual.conditions) > s.minConditions {
+		index := 1 + random.IntN(len(individual.conditions) - 1)
+		individual.conditions = slices.Delete(individual.conditions, index, index + 1)
+	}
+	if mutates() {
+		individual.side = random.IntN(len(s.sides))
+	}
+	if mutates() {
+		individual.optimizeWeekdays = s.optimizeWeekdaysModes[random.IntN(len(s.optimizeWeekdaysModes))]
+	}
+	if mutates() {
+		individual.timeOfDay = random.IntN(len(s.timesOfDay))
+	}
+	if mutates() {
+		individual.returns = random.IntN(len(s.returnsAccessors))
+	}
+	if mutates() {
+		individual.stopLoss = random.IntN(len(s.stopLossLimits))
+	}
+}
+
+// Conditions must refer to distinct features, the ones following the first condition are sorted so that permutations share a key
+func (s *geneticSearch) repair(individual *geneticIndividual, random *rand.Rand) {
+	conditions := []geneticCondition{individual.conditions[0]}
+	for _, condition := range individual.conditions[1:] {
+		for range geneticRepairAttempts {
+			if !slices.ContainsFunc(conditions, condition.sameFeature) {
+				break
+			}
+			condition = s.newCondition(false, random)
+		}
+		if !slices.ContainsFunc(conditions, condition.sameFeature) {
+			conditions = append(conditions, condition)
+		}
+	}
+	for len(conditions) < s.minConditions {
+		condition := s.newCondition(false, random)
+		if !slices.ContainsFunc(conditions, condition.sameFeature) {
+			conditions = append(conditions, condition)
+		}
+	}
+	slices.SortFunc(conditions[1:], func (a, b geneticCondition) int {
+		if a.asset != b.asset {
+			return a.asset - b.asset
+		}
+		return a.feature - b.feature
+	})
+	individual.conditions = conditions
+}
+
+func (c geneticCondition) sameFeature(other geneticCondition) bool {
+	return c.asset == other.asset && c.feature == other.feature
+}
+
+func (i geneticIndividual) getKey() string {
+	return fmt.Sprintf("%v %d %t %d %d %d", i.conditions, i.side, i.optimizeWeekdays, i.timeOfDay, i.returns, i.stopLoss)
+}
+
+func (i geneticIndividual) clone() geneticIndividual {
+	i.conditions = slices.Clone(i.conditions)
+	return i
+}
+
+func (c *GeneticConfiguration) validate() {
+	if c.Population < 2 {
+		log.Fatalf("Invalid genetic search population: %d", c.Population)
+	}
+	if c.Generations <= 0 {
+		log.Fatalf("Invalid number of generations: %d", c.Generations)
+	}
+	if c.MutationRate != nil && (*c.MutationRate < 0.0 || *c.MutationRate > 1.0) {
+		log.Fatalf("Invalid mutation rate: %.2f", *c.MutationRate)
+	}
+	switch c.getFitness() {
+	case geneticFitnessSharpe, geneticFitnessMinSharpe, geneticFitnessRecentSharpe:
+	default:
+		log.Fatalf("Invalid genetic search fitness metric \"%s\"", c.Fitness)
+	}
+}
+
+func (c *GeneticConfiguration) getSeeds() []uint64 {
+	if len(c.Seeds) == 0 {
+		return []uint64{defaultGeneticSeed}
+	}
+	return c.Seeds
+}
+
+func (c *GeneticConfiguration) getMutationRate() float64 {
+	if c.MutationRate == nil {
+		return defaultGeneticMutationRate
+	}
+	return *c.MutationRate
+}
+
+func (c *GeneticConfiguration) getFitness() string {
+	if c.Fitness == "" {
+		return geneticFitnessSharpe
+	}
+	return c.Fitness
+}
+
+// Strategies rejected by postProcessBacktests are never selected over ones that passed
+func (c *GeneticConfiguration) getFitnessValue(backtest backtestData) float64 {
+	if !backtest.enabled {
+		return math.Inf(-1)
+	}
+	var fitness float64
+	switch c.getFitness() {
+	case geneticFitnessMinSharpe:
+		fitness = backtest.minSharpe
+	case geneticFitnessRecentSharpe:
+		fitness = backtest.recentSharpe
+	default:
+		fitness = backtest.sharpe
+	}
+	if math.IsNaN(fitness) {
+		return math.Inf(-1)
+	}
+	return fitness
+}
\ No newline at end of file
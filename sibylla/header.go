@@ -0,0 +1,250 @@
+package sibylla
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// Archives without a header are treated as version 1 and migrated when they are read
+const archiveVersion = 2
+
+type ArchiveHeader struct {
+	Version int
+	Created time.Time
+	CodeVersion string
+	FNumber int
+	Parameters ArchiveParameters
+	Sources []SourceChecksum
+	Features []FeatureDefinition
+}
+
+type ArchiveParameters struct {
+	CutoffDate time.Time
+	QuantileTransform bool
+	QuantileBufferSize int
+	QuantileStride int
+	Timezone string
+	Calendar string
+	Adjustment string
+	RollRule string
+	LegacyCutoff string
+	FirstFilterContract string
+	LastFilterContract string
+	IncludeMonths []string
+	ExcludeMonths []string
+	ExcludeRecords []time.Time
+	AssetCutoffDate time.Time
+	Legs string
+}
+
+type SourceChecksum struct {
+	Path string
+	SHA256 string
+	// Size of the file and the state of the hash at that size, so that updates only need to hash the rows appended since
+	Size int64
+	HashState []byte
+	Checkpoints []SourceCheckpoint
+}
+
+type parameterValue struct {
+	name string
+	value any
+}
+
+func newArchiveHeader(asset *Asset, fNumber int, sources []SourceChecksum) ArchiveHeader {
+	return ArchiveHeader{
+		Version: archiveVersion,
+		Created: time.Now().UTC(),
+		CodeVersion: getCodeVersion(),
+		FNumber: fNumber,
+		Parameters: newArchiveParameters(asset),
+		Sources: sources,
+		Features: *featureCatalog,
+	}
+}
+
+func newArchiveParameters(asset *Asset) ArchiveParameters {
+	getSymbol := func (symbol *GlobexCode) string {
+		if symbol == nil {
+			return ""
+		}
+		return symbol.String()
+	}
+	excludeRecords := []time.Time{}
+	for _, excludedTime := range asset.ExcludeRecords {
+		excludeRecords = append(excludeRecords, excludedTime.Time)
+	}
+	var assetCutoffDate time.Time
+	if asset.CutoffDate != nil {
+		assetCutoffDate = asset.CutoffDate.Time
+	}
+	return ArchiveParameters{
+		CutoffDate: configuration.CutoffDate.Time,
+		QuantileTransform: configuration.QuantileTransform,
+		QuantileBufferSize: configuration.QuantileBufferSize,
+		QuantileStride: configuration.QuantileStride,
+		Timezone: asset.Timezone,
+		Calendar: asset.Calendar,
+		Adjustment: asset.getAdjustment(),
+		RollRule: asset.getRollRule(),
+		LegacyCutoff: getSymbol(asset.LegacyCutoff),
+		FirstFilterContract: getSymbol(asset.FirstFilterContract),
+		LastFilterContract: getSymbol(asset.LastFilterContract),
+		IncludeMonths: asset.IncludeMonths,
+		ExcludeMonths: asset.ExcludeMonths,
+		ExcludeRecords: excludeRecords,
+		AssetCutoffDate: assetCutoffDate,
+		Legs: asset.getLegsString(),
+	}
+}
+
+func (p *ArchiveParameters) getValues() []parameterValue {
+	return []parameterValue{
+		{"cutoffDate", getDateString(p.CutoffDate)},
+		{"quantileTransform", p.QuantileTransform},
+		{"quantileBufferSize", p.QuantileBufferSize},
+		{"quantileStride", p.QuantileStride},
+		{"timezone", p.Timezone},
+		{"calendar", p.Calendar},
+		{"adjustment", p.Adjustment},
+		{"roll", p.RollRule},
+		{"legacyCutoff", p.LegacyCutoff},
+		{"firstFilterContract", p.FirstFilterContract},
+		{"lastFilterContract", p.LastFilterContract},
+		{"includeMonths", strings.Join(p.IncludeMonths, ", ")},
+		{"excludeMonths", strings.Join(p.ExcludeMonths, ", ")},
+		{"excludeRecords", len(p.ExcludeRecords)},
+		{"assetCutoffDate", getDateString(p.AssetCutoffDate)},
+		{"legs", p.Legs},
+	}
+}
+
+// Returns descriptions of the generation parameters that differ from the current configuration
+func (h *ArchiveHeader) getMismatches(asset *Asset) []string {
+	mismatches := []string{}
+	current := newArchiveParameters(asset)
+	currentValues := current.getValues()
+	for i, value := range h.Parameters.getValues() {
+		archiveString := fmt.Sprint(value.value)
+		currentString := fmt.Sprint(currentValues[i].value)
+		if archiveString != currentString {
+			mismatch := fmt.Sprintf("%s: archive = %s, configuration = %s", value.name, archiveString, currentString)
+			mismatches = append(mismatches, mismatch)
+		}
+	}
+	if fmt.Sprint(h.Parameters.ExcludeRecords) != fmt.Sprint(current.ExcludeRecords) && len(h.Parameters.ExcludeRecords) == len(current.ExcludeRecords) {
+		mismatches = append(mismatches, "excludeRecords: timestamps differ")
+	}
+	for _, archiveFeature := range h.Features {
+		if !h.Parameters.QuantileTransform || !configuration.QuantileTransform {
+			break
+		}
+		currentFeature, exists := find(*featureCatalog, func (d FeatureDefinition) bool {
+			return d.Name == archiveFeature.Name
+		})
+		if exists && archiveFeature.getNormalization() != currentFeature.getNormalization() {
+			mismatch := fmt.Sprintf("%s normalization: archive = %s, configuration = %s", archiveFeature.Name, archiveFeature.getNormalization(), currentFeature.getNormalization())
+			mismatches = append(mismatches, mismatch)
+		}
+	}
+	return mismatches
+}
+
+func (h *ArchiveHeader) print(path string, mismatches []string) {
+	fmt.Printf("Archive: %s\n", path)
+	if h.Version == 1 {
+		fmt.Printf("\tFormat version:      1 (legacy, no generation parameters)\n")
+		return
+	}
+	fmt.Printf("\tFormat version:      %d\n", h.Version)
+	fmt.Printf("\tCreated:             %s\n", getTimeString(h.Created))
+	fmt.Printf("\tCode version:        %s\n", h.CodeVersion)
+	fmt.Printf("\tF-number:            %d\n", h.FNumber)
+	for _, value := range h.Parameters.getValues() {
+		fmt.Printf("\t%-21s%v\n", value.name + ":", value.value)
+	}
+	for _, source := range h.Sources {
+		fmt.Printf("\tSource:              %s (SHA-256 %s)\n", source.Path, source.SHA256)
+	}
+	featureNames := []string{}
+	for _, definition := range h.Features {
+		featureNames = append(featureNames, definition.Name)
+	}
+	fmt.Printf("\tFeatures:            %s\n", strings.Join(featureNames, ", "))
+	for _, mismatch := range mismatches {
+		fmt.Printf("\tMismatch:            %s\n", mismatch)
+	}
+}
+
+func getCodeVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	revision := ""
+	modified := false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision == "" {
+		return info.Main.Version
+	}
+	if modified {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+func getSourceChecksums(source DataSource, index *sourceIndex) []SourceChecksum {
+	checksums := []SourceChecksum{}
+	for _, path := range source.getPaths() {
+		file, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("Failed to open data source %s: %v", path, err)
+		}
+		hash := sha256.New()
+		var offset int64
+		previous, exists := index.getPrevious(path)
+		if exists && len(previous.HashState) > 0 {
+			err = hash.(encoding.BinaryUnmarshaler).UnmarshalBinary(previous.HashState)
+			if err == nil {
+				offset, err = file.Seek(previous.Size, io.SeekStart)
+			}
+			if err != nil {
+				hash.Reset()
+				offset, _ = file.Seek(0, io.SeekStart)
+			}
+		}
+		size, err := io.Copy(hash, file)
+		file.Close()
+		if err != nil {
+			log.Fatalf("Failed to calculate checksum of data source %s: %v", path, err)
+		}
+		state, err := hash.(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			log.Fatalf("Failed to serialize checksum state of data source %s: %v", path, err)
+		}
+		checksum := SourceChecksum{
+			Path: path,
+			SHA256: hex.EncodeToString(hash.Sum(nil)),
+			Size: offset + size,
+			HashState: state,
+			Checkpoints: index.getCheckpoints(path),
+		}
+		checksums = append(checksums, checksum)
+	}
+	return checksums
+}
\ No newline at end of file
@@ -0,0 +1,406 @@
+package sibylla
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+type priceBar struct {
+	high float64
+	low float64
+	close float64
+}
+
+type dailyRange struct {
+	high float64
+	low float64
+	volume float64
+}
+
+type dailyRangeMap map[globexDateKey]dailyRange
+
+func getDailyRanges(intradayRecords intradayRecordsMap) dailyRangeMap {
+	ranges := dailyRangeMap{}
+	for key, record := range intradayRecords {
+		ranges.add(key, record)
+	}
+	return ranges
+}
+
+func (m dailyRangeMap) add(key globexTimeKey, record intradayRecord) {
+	dateKey := getGlobexDateKey(key.symbol, key.timestamp)
+	dayRange, exists := m[dateKey]
+	if exists {
+		dayRange.high = max(dayRange.high, record.high)
+		dayRange.low = min(dayRange.low, record.low)
+		dayRange.volume += record.volume
+	} else {
+		dayRange = dailyRange{
+			high: record.high,
+			low: record.low,
+			volume: record.volume,
+		}
+	}
+	m[dateKey] = dayRange
+}
+
+func validateBars(definition *FeatureDefinition) error {
+	if (definition.Days > 0) == (definition.Hours > 0) {
+		return fmt.Errorf("%s requires either days or hours", definition.Type)
+	}
+	if definition.LagDays > 0 {
+		return fmt.Errorf("%s does not support lagDays", definition.Type)
+	}
+	return nil
+}
+
+// Returns the bars of the last N hours or trading days in chronological order, the last one being the current bar
+func getBars(days, hours int, context *featureContext) []priceBar {
+	if days > 0 {
+		return getDailyBars(days, context)
+	} else {
+		return getHourlyBars(hours, context)
+	}
+}
+
+func getHourlyBars(hours int, context *featureContext) []priceBar {
+	series := context.series
+	bars := []priceBar{}
+	for i := hours; i >= 0; i-- {
+		timestamp := context.timestamp.Add(- time.Duration(i) * time.Hour)
+		symbol, exists := series.getSymbol(timestamp)
+		if !exists {
+			continue
+		}
+		key := getGlobexTimeKey(symbol, timestamp)
+		record, exists := context.intradayRecords[key]
+		if !exists {
+			continue
+		}
+		bar := priceBar{
+			high: series.adjust(timestamp, record.high),
+			low: series.adjust(timestamp, record.low),
+			close: series.adjust(timestamp, record.close),
+		}
+		bars = append(bars, bar)
+	}
+	return bars
+}
+
+func getDailyBars(days int, context *featureContext) []priceBar {
+	series := context.series
+	bars := []priceBar{}
+	for i := days; i >= 1; i-- {
+		timestamp := context.calendar.addTradingDays(context.timestamp, -i)
+		symbol, exists := series.getSymbol(timestamp)
+		if !exists {
+			continue
+		}
+		key := getGlobexDateKey(symbol, timestamp)
+		close, exists := context.dailyCloses[key]
+		if !exists {
+			continue
+		}
+		dayRange, exists := context.dailyRanges[key]
+		if !exists {
+			continue
+		}
+		bar := priceBar{
+			high: series.adjust(timestamp, dayRange.high),
+			low: series.adjust(timestamp, dayRange.low),
+			close: series.adjust(timestamp, close),
+		}
+		bars = append(bars, bar)
+	}
+	currentBar, _ := getSessionBar(series.symbol, context.timestamp, context)
+	bars = append(bars, currentBar)
+	return bars
+}
+
+// Bars of the last N trading days up to the same hour as the current bar, which is the last one
+func getPartialDailyBars(days int, context *featureContext) []priceBar {
+	bars := []priceBar{}
+	for i := days; i >= 1; i-- {
+		timestamp := context.calendar.addTradingDays(context.timestamp, -i)
+		symbol, exists := context.series.getSymbol(timestamp)
+		if !exists {
+			continue
+		}
+		bar, exists := getSessionBar(symbol, timestamp, context)
+		if exists {
+			bars = append(bars, bar)
+		}
+	}
+	currentBar, _ := getSessionBar(context.series.symbol, context.timestamp, context)
+	bars = append(bars, currentBar)
+	return bars
+}
+
+// High and low from the start of the day up to and including the hour of the timestamp
+func getSessionBar(symbol GlobexCode, timestamp time.Time, context *featureContext) (priceBar, bool) {
+	series := context.series
+	record, exists := context.intradayRecords[getGlobexTimeKey(symbol, timestamp)]
+	if !exists {
+		return priceBar{}, false
+	}
+	bar := priceBar{
+		high: math.Inf(-1),
+		low: math.Inf(1),
+		close: series.adjust(timestamp, record.close),
+	}
+	for t := getDateFromTime(timestamp); !t.After(timestamp); t = t.Add(time.Hour) {
+		key := getGlobexTimeKey(symbol, t)
+		record, exists := context.intradayRecords[key]
+		if exists {
+			bar.high = max(bar.high, series.adjust(t, record.high))
+			bar.low = min(bar.low, series.adjust(t, record.low))
+		}
+	}
+	return bar, true
+}
+
+func getLogReturns(bars []priceBar) []float64 {
+	returns := []float64{}
+	for i := 1; i < len(bars); i++ {
+		if bars[i - 1].close <= 0 || bars[i].close <= 0 {
+			continue
+		}
+		returns = append(returns, math.Log(bars[i].close / bars[i - 1].close))
+	}
+	return returns
+}
+
+func getStandardDeviation(values []float64) (float64, bool) {
+	if len(values) < 2 {
+		return 0, false
+	}
+	mean := 0.0
+	for _, x := range values {
+		mean += x
+	}
+	mean /= float64(len(values))
+	sum := 0.0
+	for _, x := range values {
+		delta := x - mean
+		sum += delta * delta
+	}
+	return math.Sqrt(sum / float64(len(values) - 1)), true
+}
+
+func getRealizedVolatility(definition *FeatureDefinition, context *featureContext) *float64 {
+	bars := getBars(definition.Days, definition.Hours, context)
+	volatility, valid := getStandardDeviation(getLogReturns(bars))
+	if !valid {
+		return nil
+	}
+	return &volatility
+}
+
+func getAverageTrueRange(definition *FeatureDefinition, context *featureContext) *float64 {
+	bars := getBars(definition.Days, definition.Hours, context)
+	if len(bars) < 2 {
+		return nil
+	}
+	sum := 0.0
+	for i := 1; i < len(bars); i++ {
+		previousClose := bars[i - 1].close
+		high := max(bars[i].high, previousClose)
+		low := min(bars[i].low, previousClose)
+		sum += high - low
+	}
+	close := bars[len(bars) - 1].close
+	if close <= 0 {
+		return nil
+	}
+	atr := sum / float64(len(bars) - 1) / close
+	return &atr
+}
+
+// The range of the current session so far is ranked against the ranges of previous sessions up to the same hour
+func getRangePercentile(definition *FeatureDefinition, context *featureContext) *float64 {
+	var bars []priceBar
+	if definition.Days > 0 {
+		bars = getPartialDailyBars(definition.Days, context)
+	} else {
+		bars = getHourlyBars(definition.Hours, context)
+	}
+	if len(bars) < 2 {
+		return nil
+	}
+	getRange := func (bar priceBar) float64 {
+		return (bar.high - bar.low) / bar.close
+	}
+	currentBar := bars[len(bars) - 1]
+	if currentBar.close <= 0 {
+		return nil
+	}
+	currentRange := getRange(currentBar)
+	count := 0
+	for _, bar := range bars[:len(bars) - 1] {
+		if bar.close > 0 && getRange(bar) <= currentRange {
+			count++
+		}
+	}
+	percentile := float64(count) / float64(len(bars) - 1)
+	return &percentile
+}
+
+func getRelativeStrengthIndex(definition *FeatureDefinition, context *featureContext) *float64 {
+	bars := getBars(definition.Days, definition.Hours, context)
+	gains := 0.0
+	losses := 0.0
+	for i := 1; i < len(bars); i++ {
+		delta := bars[i].close - bars[i - 1].close
+		if delta > 0 {
+			gains += delta
+		} else {
+			losses -= delta
+		}
+	}
+	if gains + losses == 0 {
+		return nil
+	}
+	rsi := 100.0 * gains / (gains + losses)
+	return &rsi
+}
+
+func getDistanceFromExtreme(high bool, definition *FeatureDefinition, context *featureContext) *float64 {
+	bars := getBars(definition.Days, definition.Hours, context)
+	if len(bars) == 0 {
+		return nil
+	}
+	extreme := bars[0].low
+	if high {
+		extreme = bars[0].high
+	}
+	for _, bar := range bars[1:] {
+		if high {
+			extreme = max(extreme, bar.high)
+		} else {
+			extreme = min(extreme, bar.low)
+		}
+	}
+	close := bars[len(bars) - 1].close
+	distance, valid := getRateOfChange(close, extreme)
+	if !valid {
+		return nil
+	}
+	return &distance
+}
+
+// Momentum divided by the volatility expected over the same number of bars
+func getMomentumZScore(definition *FeatureDefinition, context *featureContext) *float64 {
+	momentum := getMomentum(definition.Days, 0, definition.Hours, context)
+	if momentum == nil {
+		return nil
+	}
+	periods := definition.Days + definition.Hours
+	windowDays := 0
+	windowHours := 0
+	if definition.Days > 0 {
+		windowDays = definition.Window
+	} else {
+		windowHours = definition.Window
+	}
+	bars := getBars(windowDays, windowHours, context)
+	volatility, valid := getStandardDeviation(getLogReturns(bars))
+	if !valid || volatility == 0 {
+		return nil
+	}
+	zScore := *momentum / (volatility * math.Sqrt(float64(periods)))
+	return &zScore
+}
+
+func validateDays(definition *FeatureDefinition) error {
+	if definition.Days <= 0 || definition.Hours > 0 || definition.LagDays > 0 {
+		return fmt.Errorf("%s requires days and no other lookback", definition.Type)
+	}
+	return nil
+}
+
+func getHourlyVolume(timestamp time.Time, context *featureContext) (float64, bool) {
+	symbol, exists := context.series.getSymbol(timestamp)
+	if !exists {
+		return 0, false
+	}
+	key := getGlobexTimeKey(symbol, timestamp)
+	record, exists := context.intradayRecords[key]
+	return record.volume, exists
+}
+
+func getDailyVolume(timestamp time.Time, context *featureContext) (float64, bool) {
+	symbol, exists := context.series.getSymbol(timestamp)
+	if !exists {
+		return 0, false
+	}
+	key := getGlobexDateKey(symbol, timestamp)
+	dayRange, exists := context.dailyRanges[key]
+	return dayRange.volume, exists
+}
+
+// Volume of the current bar relative to the mean volume of the same hour over the previous N trading days
+func getRelativeVolume(definition *FeatureDefinition, context *featureContext) *float64 {
+	sum := 0.0
+	count := 0
+	for i := 1; i <= definition.Days; i++ {
+		timestamp := context.calendar.addTradingDays(context.timestamp, -i)
+		volume, exists := getHourlyVolume(timestamp, context)
+		if exists {
+			sum += volume
+			count++
+		}
+	}
+	if count == 0 || sum <= 0 {
+		return nil
+	}
+	relativeVolume := context.record.volume / (sum / float64(count))
+	return &relativeVolume
+}
+
+// Rate of change between the total volume of the last N hours or days and the N hours or days preceding them
+func getVolumeMomentum(definition *FeatureDefinition, context *featureContext) *float64 {
+	periods := definition.Days + definition.Hours
+	getVolume := func (offset int) (float64, bool) {
+		if definition.Days > 0 {
+			timestamp := context.calendar.addTradingDays(context.timestamp, -offset - 1)
+			return getDailyVolume(timestamp, context)
+		} else {
+			timestamp := context.timestamp.Add(- time.Duration(offset) * time.Hour)
+			return getHourlyVolume(timestamp, context)
+		}
+	}
+	windows := [2]float64{}
+	for i := range 2 * periods {
+		volume, exists := getVolume(i)
+		if exists {
+			windows[i / periods] += volume
+		}
+	}
+	momentum, valid := getRateOfChange(windows[0], windows[1])
+	if !valid {
+		return nil
+	}
+	return &momentum
+}
+
+// Open interest is only known after the close, so the change is measured up to the previous trading day
+func getOpenInterestChange(definition *FeatureDefinition, context *featureContext) *float64 {
+	symbol := context.series.symbol
+	getOpenInterest := func (days int) (int, bool) {
+		timestamp := context.calendar.addTradingDays(context.timestamp, -days)
+		key := getGlobexDateKey(symbol, timestamp)
+		openInterest, exists := context.dailyOpenInterest[key]
+		return openInterest, exists
+	}
+	openInterest1, exists1 := getOpenInterest(1)
+	openInterest2, exists2 := getOpenInterest(definition.Days + 1)
+	if !exists1 || !exists2 {
+		return nil
+	}
+	change, valid := getRateOfChange(float64(openInterest1), float64(openInterest2))
+	if !valid {
+		return nil
+	}
+	return &change
+}
\ No newline at end of file
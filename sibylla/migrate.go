@@ -0,0 +1,141 @@
+package sibylla
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Archives without a header were generated from naive timestamps in the timezone of Barchart data
+const legacyArchiveTimezone = "America/Chicago"
+
+type legacyArchive struct {
+	Symbol string
+	Timezone string
+	Features []string
+	HoldingTimes []int
+	DailyRecords []DailyRecord
+	IntradayRecords []legacyFeatureRecord
+	Rolls []Roll
+	Bars []BarSeries
+}
+
+// Union of the record layouts used by archives without a header
+type legacyFeatureRecord struct {
+	Timestamp time.Time
+	Features []float64
+	Returns []ReturnsRecord
+	Momentum1D *float64
+	Momentum1DLag *float64
+	Momentum2D *float64
+	Momentum5D *float64
+	Momentum10D *float64
+	Momentum1H *float64
+	Momentum2H *float64
+	Momentum4H *float64
+	Momentum8H *float64
+	Returns4H *ReturnsRecord
+	Returns8H *ReturnsRecord
+	Returns16H *ReturnsRecord
+	Returns24H *ReturnsRecord
+	Returns48H *ReturnsRecord
+	Returns72H *ReturnsRecord
+}
+
+func readLegacyArchive(path string) Archive {
+	var legacy legacyArchive
+	decodeArchive(path, func (decoder *gob.Decoder) error {
+		return decoder.Decode(&legacy)
+	})
+	fmt.Printf("[%s] Migrating legacy archive without a header, regenerate it to record generation parameters: %s\n", legacy.Symbol, path)
+	archive := Archive{
+		Symbol: legacy.Symbol,
+		Timezone: legacy.Timezone,
+		Features: legacy.Features,
+		HoldingTimes: legacy.HoldingTimes,
+		DailyRecords: legacy.DailyRecords,
+		Rolls: legacy.Rolls,
+		Bars: legacy.Bars,
+	}
+	if archive.Features == nil {
+		archive.Features = []string{
+			"momentum1D",
+			"momentum1DLag",
+			"momentum2D",
+			"momentum5D",
+			"momentum10D",
+			"momentum1H",
+			"momentum2H",
+			"momentum4H",
+			"momentum8H",
+		}
+	}
+	if archive.HoldingTimes == nil {
+		archive.HoldingTimes = []int{4, 8, 16, 24, 48, 72}
+	}
+	location := getLocation(legacyArchiveTimezone)
+	if archive.Timezone == "" {
+		// Keeps the wall clock times the legacy timestamps were mined in
+		archive.Timezone = legacyArchiveTimezone
+	}
+	for _, legacyRecord := range legacy.IntradayRecords {
+		record := FeatureRecord{
+			Timestamp: getUTCTime(legacyRecord.Timestamp, location),
+			Features: legacyRecord.Features,
+			Returns: legacyRecord.Returns,
+		}
+		if record.Features == nil {
+			record.Features = legacyRecord.getFeatures()
+		}
+		if record.Returns == nil {
+			record.Returns = legacyRecord.getReturns()
+		}
+		archive.IntradayRecords = append(archive.IntradayRecords, record)
+	}
+	return archive
+}
+
+func (r *legacyFeatureRecord) getFeatures() []float64 {
+	pointers := []*float64{
+		r.Momentum1D,
+		r.Momentum1DLag,
+		r.Momentum2D,
+		r.Momentum5D,
+		r.Momentum10D,
+		r.Momentum1H,
+		r.Momentum2H,
+		r.Momentum4H,
+		r.Momentum8H,
+	}
+	features := []float64{}
+	for _, pointer := range pointers {
+		if pointer != nil {
+			features = append(features, *pointer)
+		} else {
+			features = append(features, math.NaN())
+		}
+	}
+	return features
+}
+
+func (r *legacyFeatureRecord) getReturns() []ReturnsRecord {
+	pointers := []*ReturnsRecord{
+		r.Returns4H,
+		r.Returns8H,
+		r.Returns16H,
+		r.Returns24H,
+		r.Returns48H,
+		r.Returns72H,
+	}
+	returns := []ReturnsRecord{}
+	for _, pointer := range pointers {
+		returnsRecord := ReturnsRecord{}
+		if pointer != nil {
+			returnsRecord = *pointer
+			returnsRecord.Valid = true
+		}
+		returns = append(returns, returnsRecord)
+	}
+	return returns
+}
\ No newline at end of file
@@ -0,0 +1,60 @@
+package sibylla
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLegacyArchiveTimestamps(t *testing.T) {
+	momentum := 0.25
+	// Naive US Central timestamps during standard and daylight saving time
+	winter := time.Date(2024, time.January, 2, 9, 0, 0, 0, time.UTC)
+	summer := time.Date(2024, time.July, 2, 9, 0, 0, 0, time.UTC)
+	legacy := legacyArchive{
+		Symbol: "ES",
+		DailyRecords: []DailyRecord{
+			{Date: getDateFromTime(winter), Close: 4750.25},
+		},
+		IntradayRecords: []legacyFeatureRecord{
+			{Timestamp: winter, Momentum1D: &momentum},
+			{Timestamp: summer, Momentum1D: &momentum},
+		},
+	}
+	path := filepath.Join(t.TempDir(), "ES." + archiveExtension)
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := gzip.NewWriter(file)
+	err = gob.NewEncoder(writer).Encode(&legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Close()
+	file.Close()
+	archive := readArchive(path)
+	if archive.Timezone != legacyArchiveTimezone {
+		t.Errorf("Migrated archive has timezone \"%s\", expected \"%s\"", archive.Timezone, legacyArchiveTimezone)
+	}
+	expected := []time.Time{
+		time.Date(2024, time.January, 2, 15, 0, 0, 0, time.UTC),
+		time.Date(2024, time.July, 2, 14, 0, 0, 0, time.UTC),
+	}
+	if len(archive.IntradayRecords) != len(expected) {
+		t.Fatalf("Migrated archive has %d intraday records, expected %d", len(archive.IntradayRecords), len(expected))
+	}
+	location := getLocation(archive.Timezone)
+	for i, record := range archive.IntradayRecords {
+		if !record.Timestamp.Equal(expected[i]) {
+			t.Errorf("Record %d: timestamp = %s, expected %s", i, getTimeString(record.Timestamp), getTimeString(expected[i]))
+		}
+		localTime := getLocalTime(record.Timestamp, location)
+		if !localTime.Equal(legacy.IntradayRecords[i].Timestamp) {
+			t.Errorf("Record %d: local time = %s, expected %s", i, getTimeString(localTime), getTimeString(legacy.IntradayRecords[i].Timestamp))
+		}
+	}
+}
\ No newline at end of file
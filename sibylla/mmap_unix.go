@@ -0,0 +1,33 @@
+//go:build !windows
+
+package sibylla
+
+import (
+	"log"
+	"os"
+	"syscall"
+)
+
+func mapFile(path string) ([]byte, func ()) {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+	fileInfo, err := file.Stat()
+	if err != nil {
+		log.Fatalf("Failed to retrieve size of %s: %v", path, err)
+	}
+	size := int(fileInfo.Size())
+	if size == 0 {
+		return []byte{}, func () {}
+	}
+	data, err := syscall.Mmap(int(file.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		log.Fatalf("Failed to map %s: %v", path, err)
+	}
+	unmap := func () {
+		syscall.Munmap(data)
+	}
+	return data, unmap
+}
\ No newline at end of file
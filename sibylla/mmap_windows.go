@@ -0,0 +1,40 @@
+package sibylla
+
+import (
+	"log"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+func mapFile(path string) ([]byte, func ()) {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+	fileInfo, err := file.Stat()
+	if err != nil {
+		log.Fatalf("Failed to retrieve size of %s: %v", path, err)
+	}
+	size := fileInfo.Size()
+	if size == 0 {
+		return []byte{}, func () {}
+	}
+	handle, err := syscall.CreateFileMapping(syscall.Handle(file.Fd()), nil, syscall.PAGE_READONLY, 0, 0, nil)
+	if err != nil {
+		log.Fatalf("Failed to create file mapping for %s: %v", path, err)
+	}
+	defer syscall.CloseHandle(handle)
+	address, err := syscall.MapViewOfFile(handle, syscall.FILE_MAP_READ, 0, 0, uintptr(size))
+	if err != nil {
+		log.Fatalf("Failed to map %s: %v", path, err)
+	}
+	// The view is not managed by the Go heap, so reinterpreting the address is safe
+	pointer := *(*unsafe.Pointer)(unsafe.Pointer(&address))
+	data := unsafe.Slice((*byte)(pointer), size)
+	unmap := func () {
+		syscall.UnmapViewOfFile(address)
+	}
+	return data, unmap
+}
\ No newline at end of file
@@ -2,12 +2,16 @@ package sibylla
 
 import (
 	"log"
+	"math"
+	"slices"
 	"sort"
 )
 
 type accessorBuffer struct {
 	accessor featureAccessor
 	buffer []featureIndex
+	mean float64
+	stdDev float64
 }
 
 type featureIndex struct {
@@ -15,15 +19,24 @@ type featureIndex struct {
 	index int
 }
 
-func quantileTransform(bufferSize, stride int, input []FeatureRecord) []FeatureRecord {
-	bufferSize = min(bufferSize, len(input))
+// Rolling windows are aligned to the stride so that the tail of an archive can be updated without changing earlier records.
+// As a result a record may be ranked against up to stride - 1 later records, the records of the first window are ranked against the whole first window
+// and those in the final stride against the final window. Anchored ranks of the first window are computed the same way.
+// A non-zero base is the archive index of input[0] when only the tail of an archive is being updated
+func quantileTransform(bufferSize, stride, base int, input []FeatureRecord) []FeatureRecord {
+	bufferSize = min(bufferSize, base + len(input))
 	if stride >= bufferSize {
 		log.Fatalf("Invalid stride for quantile transform (stride = %d, bufferSize = %d)", stride, bufferSize)
 	}
 	output := make([]FeatureRecord, len(input))
 	copy(output, input)
-	anchoredQuantileTransform(bufferSize, input, output)
-	rollingQuantileTransform(bufferSize, stride, input, output)
+	for i := range output {
+		output[i].Features = slices.Clone(input[i].Features)
+	}
+	if base == 0 {
+		anchoredQuantileTransform(bufferSize, input, output)
+	}
+	rollingQuantileTransform(bufferSize, stride, base, input, output)
 	return output
 }
 
@@ -35,7 +48,7 @@ func anchoredQuantileTransform(
 	accessors := getFeatureAccessors()
 	accessorBuffers := []accessorBuffer{}
 	for _, accessor := range accessors {
-		if accessor.anchored {
+		if accessor.normalization == normalizationAnchoredRank {
 			accBuffer := newAccessorBuffer(len(input), accessor)
 			accessorBuffers = append(accessorBuffers, accBuffer)
 		}
@@ -67,20 +80,24 @@ func anchoredQuantileTransform(
 func rollingQuantileTransform(
 	bufferSize int,
 	stride int,
+	base int,
 	input []FeatureRecord,
 	output []FeatureRecord,
 ) {
 	accessors := getFeatureAccessors()
 	accessorBuffers := []accessorBuffer{}
 	for _, accessor := range accessors {
-		if !accessor.anchored {
+		if accessor.normalization == normalizationRank || accessor.normalization == normalizationZScore {
 			accBuffer := newAccessorBuffer(bufferSize, accessor)
 			accessorBuffers = append(accessorBuffers, accBuffer)
 		}
 	}
-	writeQuantileRecords(0, bufferSize, bufferSize, accessorBuffers, input, output)
-	for offset := stride; offset + bufferSize < len(input); offset += stride {
-		writeQuantileRecords(offset, bufferSize, stride, accessorBuffers, input, output)
+	if base == 0 {
+		writeQuantileRecords(0, bufferSize, bufferSize, accessorBuffers, input, output)
+	}
+	firstOffset := max((base + stride - 1) / stride * stride, stride)
+	for offset := firstOffset; offset + bufferSize < base + len(input); offset += stride {
+		writeQuantileRecords(offset - base, bufferSize, stride, accessorBuffers, input, output)
 	}
 	writeQuantileRecords(len(input) - bufferSize, bufferSize, stride, accessorBuffers, input, output)
 }
@@ -101,6 +118,7 @@ func writeQuantileRecords(
 	for i := range accessorBuffers {
 		accBuffer := &accessorBuffers[i]
 		accBuffer.sort()
+		accBuffer.updateMoments()
 		for j, featIndex := range accBuffer.buffer {
 			if featIndex.index >= offset + bufferSize - updateRange {
 				accBuffer.apply(j, featIndex, output)
@@ -109,6 +127,45 @@ func writeQuantileRecords(
 	}
 }
 
+// Normalizes only the record at index using no records after it.
+// Rolling normalizations use the trailing window ending at index, anchored ranks all records up to index
+func causalQuantileTransformRecord(bufferSize, index int, input []FeatureRecord) FeatureRecord {
+	start := max(index - bufferSize + 1, 0)
+	rollingBuffers := []accessorBuffer{}
+	anchoredBuffers := []accessorBuffer{}
+	for _, accessor := range getFeatureAccessors() {
+		switch accessor.normalization {
+		case normalizationRank, normalizationZScore:
+			rollingBuffers = append(rollingBuffers, newAccessorBuffer(index - start + 1, accessor))
+		case normalizationAnchoredRank:
+			anchoredBuffers = append(anchoredBuffers, newAccessorBuffer(index + 1, accessor))
+		}
+	}
+	filAccessorBuffers(start, index - start, input, rollingBuffers)
+	filAccessorBuffers(0, index, input, anchoredBuffers)
+	accessorBuffers := slices.Concat(rollingBuffers, anchoredBuffers)
+	record := input[index]
+	record.Features = slices.Clone(record.Features)
+	// The destination only contains the record at index
+	destination := []FeatureRecord{record}
+	for i := range accessorBuffers {
+		accBuffer := &accessorBuffers[i]
+		value := accBuffer.accessor.get(&input[index])
+		if value == nil {
+			continue
+		}
+		featIndex := featureIndex{
+			value: *value,
+			index: 0,
+		}
+		accBuffer.sort()
+		position := accBuffer.insert(featIndex)
+		accBuffer.updateMoments()
+		accBuffer.apply(position, featIndex, destination)
+	}
+	return destination[0]
+}
+
 func newAccessorBuffer(bufferSize int, accessor featureAccessor) accessorBuffer {
 	return accessorBuffer{
 		accessor: accessor,
@@ -122,8 +179,33 @@ func (accBuffer *accessorBuffer) sort() {
 	})
 }
 
+func (accBuffer *accessorBuffer) updateMoments() {
+	if accBuffer.accessor.normalization != normalizationZScore || len(accBuffer.buffer) < 2 {
+		return
+	}
+	sum := 0.0
+	for _, featIndex := range accBuffer.buffer {
+		sum += featIndex.value
+	}
+	accBuffer.mean = sum / float64(len(accBuffer.buffer))
+	deltaSum := 0.0
+	for _, featIndex := range accBuffer.buffer {
+		delta := featIndex.value - accBuffer.mean
+		deltaSum += delta * delta
+	}
+	accBuffer.stdDev = math.Sqrt(deltaSum / float64(len(accBuffer.buffer) - 1))
+}
+
 func (accBuffer *accessorBuffer) apply(i int, featIndex featureIndex, output []FeatureRecord) {
 	destination := &output[featIndex.index]
+	if accBuffer.accessor.normalization == normalizationZScore {
+		zScore := 0.0
+		if accBuffer.stdDev > 0 {
+			zScore = (featIndex.value - accBuffer.mean) / accBuffer.stdDev
+		}
+		accBuffer.accessor.set(destination, zScore)
+		return
+	}
 	quantile := float64(i) / float64(len(accBuffer.buffer) - 1)
 	accBuffer.accessor.set(destination, quantile)
 }
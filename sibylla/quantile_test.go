@@ -0,0 +1,186 @@
+package sibylla
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func setQuantileTestConfiguration(bufferSize, stride int) {
+	configuration = &Configuration{
+		QuantileTransform: true,
+		QuantileBufferSize: bufferSize,
+		QuantileStride: stride,
+	}
+	featureCatalog = &[]FeatureDefinition{
+		{Name: "rank", Normalization: normalizationRank},
+		{Name: "zScore", Normalization: normalizationZScore},
+		{Name: "anchored", Normalization: normalizationAnchoredRank},
+	}
+}
+
+// Few distinct values so that ranks contain ties, with some missing values
+func getQuantileTestRecords(count int, seed int64) []FeatureRecord {
+	random := rand.New(rand.NewSource(seed))
+	columns := len(getFeatureColumns())
+	records := make([]FeatureRecord, count)
+	for i := range records {
+		features := make([]float64, columns)
+		for j := range features {
+			if random.Intn(10) == 0 {
+				features[j] = math.NaN()
+			} else {
+				features[j] = float64(random.Intn(20))
+			}
+		}
+		records[i].Features = features
+	}
+	return records
+}
+
+func equalFeature(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func getFeatureString(value *float64) string {
+	if value == nil {
+		return "missing"
+	}
+	return strconv.FormatFloat(*value, 'g', -1, 64)
+}
+
+func TestIncrementalQuantileTransform(t *testing.T) {
+	tests := []struct {
+		name string
+		bufferSize int
+		stride int
+		records int
+		keepRecords int
+	}{
+		{"aligned base", 20, 5, 200, 140},
+		{"unaligned base", 20, 5, 200, 143},
+		{"final window only", 20, 5, 200, 195},
+		{"large stride", 30, 29, 250, 120},
+		{"base below stride", 20, 7, 100, 23},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func (t *testing.T) {
+			setQuantileTestConfiguration(test.bufferSize, test.stride)
+			input := getQuantileTestRecords(test.records, 1)
+			full := quantileTransform(test.bufferSize, test.stride, 0, input)
+			// Same base as newArchiveUpdate
+			base := test.keepRecords - test.bufferSize
+			tail := quantileTransform(test.bufferSize, test.stride, base, input[base:])
+			for _, accessor := range getFeatureAccessors() {
+				// Anchored ranks are not updated incrementally
+				if accessor.normalization != normalizationRank && accessor.normalization != normalizationZScore {
+					continue
+				}
+				for i := test.keepRecords; i < test.records; i++ {
+					expected := accessor.get(&full[i])
+					actual := accessor.get(&tail[i - base])
+					if !equalFeature(expected, actual) {
+						t.Fatalf("%s of record %d: full = %s, incremental = %s", accessor.name, i, getFeatureString(expected), getFeatureString(actual))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCausalQuantileTransformRecord(t *testing.T) {
+	tests := []struct {
+		name string
+		bufferSize int
+		records int
+	}{
+		{"single window", 20, 20},
+		{"rolling windows", 20, 103},
+		{"buffer larger than input", 50, 30},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func (t *testing.T) {
+			setQuantileTestConfiguration(test.bufferSize, 5)
+			input := getQuantileTestRecords(test.records, 2)
+			accessors := getFeatureAccessors()
+			for i := range input {
+				record := causalQuantileTransformRecord(test.bufferSize, i, input)
+				// Replacing all later records must not change the result
+				modified := slices.Concat(input[:i + 1], getQuantileTestRecords(test.records - i - 1, int64(i) + 3))
+				modifiedRecord := causalQuantileTransformRecord(test.bufferSize, i, modified)
+				for _, accessor := range accessors {
+					actual := accessor.get(&record)
+					if !equalFeature(actual, accessor.get(&modifiedRecord)) {
+						t.Fatalf("%s of record %d depends on later records", accessor.name, i)
+					}
+					if accessor.normalization == normalizationRaw {
+						continue
+					}
+					start := max(i - test.bufferSize + 1, 0)
+					if accessor.normalization == normalizationAnchoredRank {
+						start = 0
+					}
+					expected := getCausalQuantileTestValue(accessor, input[start:i + 1])
+					if !equalFeature(expected, actual) {
+						t.Fatalf("%s of record %d: expected = %s, actual = %s", accessor.name, i, getFeatureString(expected), getFeatureString(actual))
+					}
+				}
+			}
+		})
+	}
+}
+
+// Normalizes the last record of the window the straightforward way, ties are ranked at their lowest position
+func getCausalQuantileTestValue(accessor featureAccessor, window []FeatureRecord) *float64 {
+	value := accessor.get(&window[len(window) - 1])
+	if value == nil {
+		return nil
+	}
+	values := []float64{}
+	for i := range window {
+		windowValue := accessor.get(&window[i])
+		if windowValue != nil {
+			values = append(values, *windowValue)
+		}
+	}
+	// Summed in the same order as the sorted buffers
+	slices.Sort(values)
+	var output float64
+	switch accessor.normalization {
+	case normalizationZScore:
+		if len(values) < 2 {
+			break
+		}
+		mean := 0.0
+		for _, x := range values {
+			mean += x
+		}
+		mean /= float64(len(values))
+		deltaSum := 0.0
+		for _, x := range values {
+			deltaSum += (x - mean) * (x - mean)
+		}
+		stdDev := math.Sqrt(deltaSum / float64(len(values) - 1))
+		if stdDev > 0 {
+			output = (*value - mean) / stdDev
+		}
+	default:
+		// A single value has no rank and is stored as missing
+		if len(values) < 2 {
+			return nil
+		}
+		position := 0
+		for _, x := range values {
+			if x < *value {
+				position++
+			}
+		}
+		output = float64(position) / float64(len(values) - 1)
+	}
+	return &output
+}
\ No newline at end of file
@@ -0,0 +1,132 @@
+package sibylla
+
+import (
+	"log"
+	"sort"
+	"strconv"
+)
+
+const riskFreeRateCarryForward = "carryForward"
+const riskFreeRateInterpolate = "interpolate"
+const defaultRiskFreeRateDateColumn = "observation_date"
+const legacyRiskFreeRateColumn = "TB3MS"
+
+type RiskFreeRateConfiguration struct {
+	Currency string `yaml:"currency"`
+	Path string `yaml:"path"`
+	DateColumn string `yaml:"dateColumn"`
+	RateColumn string `yaml:"rateColumn"`
+}
+
+// Monthly means of annual rates in percent, sorted by month
+type riskFreeRateSeries struct {
+	currency string
+	months []int
+	rates []float64
+	interpolate bool
+}
+
+var riskFreeRate *riskFreeRateSeries
+
+// Only the series matching the account currency is loaded, riskFreeRatePath is the legacy USD series from FRED
+func loadRiskFreeRate() {
+	if riskFreeRate != nil {
+		return
+	}
+	rateConfigs := configuration.RiskFreeRates
+	if configuration.RiskFreeRatePath != "" {
+		legacyConfig := RiskFreeRateConfiguration{
+			Currency: currencyUSD,
+			Path: configuration.RiskFreeRatePath,
+			RateColumn: legacyRiskFreeRateColumn,
+		}
+		rateConfigs = append(rateConfigs, legacyConfig)
+	}
+	currency := getAccountCurrency()
+	rateConfig, exists := find(rateConfigs, func (c RiskFreeRateConfiguration) bool {
+		return c.Currency == currency
+	})
+	if !exists {
+		log.Fatalf("No risk-free rate series has been configured for the account currency %s", currency)
+	}
+	var interpolate bool
+	switch configuration.RiskFreeRateFill {
+	case "", riskFreeRateCarryForward:
+		interpolate = false
+	case riskFreeRateInterpolate:
+		interpolate = true
+	default:
+		log.Fatalf("Invalid risk-free rate fill policy \"%s\"", configuration.RiskFreeRateFill)
+	}
+	riskFreeRate = readRiskFreeRate(rateConfig, interpolate)
+}
+
+func readRiskFreeRate(rateConfig RiskFreeRateConfiguration, interpolate bool) *riskFreeRateSeries {
+	if rateConfig.Path == "" || rateConfig.RateColumn == "" {
+		log.Fatalf("Risk-free rate series for %s requires a path and a rateColumn", rateConfig.Currency)
+	}
+	dateColumn := defaultRiskFreeRateDateColumn
+	if rateConfig.DateColumn != "" {
+		dateColumn = rateConfig.DateColumn
+	}
+	columns := []string{
+		dateColumn,
+		rateConfig.RateColumn,
+	}
+	// Daily series such as €STR are averaged over each month
+	sums := map[int]float64{}
+	counts := map[int]int{}
+	readCsv(rateConfig.Path, columns, func (values []string) {
+		date := getDate(values[0])
+		rateString := values[1]
+		if rateString == "" || rateString == "." {
+			// FRED marks missing observations with a period
+			return
+		}
+		rate, err := strconv.ParseFloat(rateString, 64)
+		if err != nil {
+			log.Fatalf("Failed to parse rate value \"%s\" in %s: %v", rateString, rateConfig.Path, err)
+		}
+		month := getMonthIndex(newMonthlyEquityKey(date))
+		sums[month] += rate
+		counts[month]++
+	})
+	if len(sums) == 0 {
+		log.Fatalf("No risk-free rate samples in %s", rateConfig.Path)
+	}
+	series := riskFreeRateSeries{
+		currency: rateConfig.Currency,
+		interpolate: interpolate,
+	}
+	for month := range sums {
+		series.months = append(series.months, month)
+	}
+	sort.Ints(series.months)
+	for _, month := range series.months {
+		series.rates = append(series.rates, sums[month] / float64(counts[month]))
+	}
+	return &series
+}
+
+func getMonthIndex(key monthlyEquityKey) int {
+	return key.year * monthsPerYear + key.month - 1
+}
+
+// Months without a sample are filled in using the fill policy, months outside of the series use the closest sample
+func (s *riskFreeRateSeries) getRate(key monthlyEquityKey) float64 {
+	month := getMonthIndex(key)
+	index := sort.SearchInts(s.months, month)
+	if index < len(s.months) && s.months[index] == month {
+		return s.rates[index]
+	}
+	if index == 0 {
+		return s.rates[0]
+	}
+	if index == len(s.months) || !s.interpolate {
+		return s.rates[index - 1]
+	}
+	previousMonth := s.months[index - 1]
+	nextMonth := s.months[index]
+	weight := float64(month - previousMonth) / float64(nextMonth - previousMonth)
+	return s.rates[index - 1] + weight * (s.rates[index] - s.rates[index - 1])
+}
\ No newline at end of file
@@ -0,0 +1,162 @@
+package sibylla
+
+import (
+	"log"
+	"sort"
+	"time"
+)
+
+const rollOpenInterest = "openInterest"
+const rollExpiry = "expiry"
+const referenceLastTrade = "lastTrade"
+const referenceFirstNotice = "firstNotice"
+
+var globexMonths = map[string]time.Month{
+	"F": time.January,
+	"G": time.February,
+	"H": time.March,
+	"J": time.April,
+	"K": time.May,
+	"M": time.June,
+	"N": time.July,
+	"Q": time.August,
+	"U": time.September,
+	"V": time.October,
+	"X": time.November,
+	"Z": time.December,
+}
+
+type RollConfiguration struct {
+	Rule string `yaml:"rule"`
+	Reference string `yaml:"reference"`
+	Days int `yaml:"days"`
+}
+
+type ExpiryRule struct {
+	MonthOffset int `yaml:"monthOffset"`
+	Day int `yaml:"day"`
+	Weekday *SerializableWeekday `yaml:"weekday"`
+	Week int `yaml:"week"`
+	LastBusinessDay bool `yaml:"lastBusinessDay"`
+	BusinessDays int `yaml:"businessDays"`
+}
+
+type Roll struct {
+	Date time.Time
+	From GlobexCode
+	To GlobexCode
+}
+
+type rollDateMap map[GlobexCode]time.Time
+
+func (a *Asset) getRollRule() string {
+	if a.Roll == nil || a.Roll.Rule == "" || a.Roll.Rule == rollOpenInterest {
+		return rollOpenInterest
+	}
+	if a.Roll.Rule != rollExpiry {
+		log.Fatalf("[%s] Invalid roll rule \"%s\"", a.Symbol, a.Roll.Rule)
+	}
+	return rollExpiry
+}
+
+func (a *Asset) getExpiryRule() *ExpiryRule {
+	var rule *ExpiryRule
+	switch a.Roll.Reference {
+	case "", referenceLastTrade:
+		rule = a.LastTrade
+	case referenceFirstNotice:
+		rule = a.FirstNotice
+	default:
+		log.Fatalf("[%s] Invalid roll reference \"%s\"", a.Symbol, a.Roll.Reference)
+	}
+	if rule == nil {
+		log.Fatalf("[%s] Missing expiry rule for roll reference \"%s\"", a.Symbol, a.Roll.Reference)
+	}
+	return rule
+}
+
+func (a *Asset) getRollDate(symbol GlobexCode, rollDates rollDateMap) time.Time {
+	rollDate, exists := rollDates[symbol]
+	if !exists {
+		rule := a.getExpiryRule()
+		calendar := a.getCalendar()
+		referenceDate := rule.getDate(symbol, calendar)
+		rollDate = calendar.addTradingDays(referenceDate, -a.Roll.Days)
+		rollDates[symbol] = rollDate
+	}
+	return rollDate
+}
+
+func (a *Asset) rankContracts(date time.Time, records []dailyRecord, rollDates rollDateMap) []dailyRecord {
+	if a.getRollRule() == rollOpenInterest {
+		sort.Slice(records, func (i, j int) bool {
+			return records[i].openInterest > records[j].openInterest
+		})
+		return records
+	}
+	activeRecords := []dailyRecord{}
+	for _, record := range records {
+		rollDate := a.getRollDate(record.symbol, rollDates)
+		if date.Before(rollDate) {
+			activeRecords = append(activeRecords, record)
+		}
+	}
+	sort.Slice(activeRecords, func (i, j int) bool {
+		return activeRecords[i].symbol.Less(activeRecords[j].symbol)
+	})
+	return activeRecords
+}
+
+func (r *ExpiryRule) getDate(symbol GlobexCode, calendar *tradingCalendar) time.Time {
+	month := time.Date(symbol.Year, symbol.getMonth(), 1, 0, 0, 0, 0, time.UTC)
+	month = month.AddDate(0, r.MonthOffset, 0)
+	var anchor time.Time
+	if r.Weekday != nil {
+		week := max(r.Week, 1)
+		anchor = month
+		for anchor.Weekday() != r.Weekday.Weekday {
+			anchor = anchor.AddDate(0, 0, 1)
+		}
+		anchor = anchor.AddDate(0, 0, 7 * (week - 1))
+	} else if r.LastBusinessDay {
+		anchor = month.AddDate(0, 1, -1)
+	} else if r.Day > 0 {
+		anchor = month.AddDate(0, 0, r.Day - 1)
+	} else {
+		log.Fatalf("Invalid expiry rule for %s, a weekday, a day or lastBusinessDay is required", symbol)
+	}
+	for !calendar.isTradingDay(anchor) {
+		anchor = anchor.AddDate(0, 0, -1)
+	}
+	return calendar.addTradingDays(anchor, r.BusinessDays)
+}
+
+func (g GlobexCode) getMonth() time.Month {
+	month, exists := globexMonths[g.Month]
+	if !exists {
+		log.Fatalf("Invalid Globex month code in %s", g)
+	}
+	return month
+}
+
+func getRolls(openIntRecords []openInterestRecords, dailyMap dailyRecordMap) []Roll {
+	rolls := []Roll{}
+	var previous *dailyRecord
+	for _, datedRecords := range openIntRecords {
+		date := datedRecords.date
+		record, exists := dailyMap[date]
+		if !exists {
+			continue
+		}
+		if previous != nil && previous.symbol != record.symbol {
+			roll := Roll{
+				Date: date,
+				From: previous.symbol,
+				To: record.symbol,
+			}
+			rolls = append(rolls, roll)
+		}
+		previous = &record
+	}
+	return rolls
+}
\ No newline at end of file
@@ -35,7 +35,7 @@ func (d *SerializableDate) UnmarshalYAML(value *yaml.Node) error {
 }
 
 func (d *SerializableDuration) UnmarshalYAML(value *yaml.Node) error {
-	pattern := regexp.MustCompile(`^(\d{2}):00`)
+	pattern := regexp.MustCompile(`^(\d{2}):(\d{2})`)
 	matches := pattern.FindStringSubmatch(value.Value)
 	if matches == nil {
 		return fmt.Errorf("unable to parse duration: %s", value.Value)
@@ -44,7 +44,11 @@ func (d *SerializableDuration) UnmarshalYAML(value *yaml.Node) error {
 	if err != nil {
 		return err
 	}
-	d.Duration = time.Duration(hours) * time.Hour
+	minutes, err := strconv.Atoi(matches[2])
+	if err != nil {
+		return err
+	}
+	d.Duration = time.Duration(hours) * time.Hour + time.Duration(minutes) * time.Minute
 	return nil
 }
 
@@ -72,6 +76,10 @@ func (w *SerializableWeekday) UnmarshalYAML(value *yaml.Node) error {
 		w.Weekday = time.Thursday
 	case "Friday":
 		w.Weekday = time.Friday
+	case "Saturday":
+		w.Weekday = time.Saturday
+	case "Sunday":
+		w.Weekday = time.Sunday
 	default:
 		return fmt.Errorf("invalid weekday string \"%s\"", value.Value)
 	}
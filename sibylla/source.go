@@ -0,0 +1,287 @@
+package sibylla
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const sourceBarchart = "barchart"
+const sourceCsv = "csv"
+const sourceSymbolPlaceholder = "{symbol}"
+
+// Intraday timestamps are passed on as wall clock times in the timezone of the exchange
+type DataSource interface {
+	readDailyRecords(callback func (time.Time, dailyRecord))
+	readIntradayRecords(callback func (globexTimeKey, intradayRecord))
+	getPaths() []string
+}
+
+type SourceConfiguration struct {
+	Type string `yaml:"type"`
+	Path string `yaml:"path"`
+	Symbol string `yaml:"symbol"`
+	DailyFile string `yaml:"dailyFile"`
+	IntradayFile string `yaml:"intradayFile"`
+	DateLayout string `yaml:"dateLayout"`
+	TimeLayout string `yaml:"timeLayout"`
+	Delimiter string `yaml:"delimiter"`
+	Timezone string `yaml:"timezone"`
+	Columns ColumnMapping `yaml:"columns"`
+}
+
+type ColumnMapping struct {
+	Symbol string `yaml:"symbol"`
+	Date string `yaml:"date"`
+	Time string `yaml:"time"`
+	Open string `yaml:"open"`
+	High string `yaml:"high"`
+	Low string `yaml:"low"`
+	Close string `yaml:"close"`
+	Volume string `yaml:"volume"`
+	OpenInterest string `yaml:"openInterest"`
+}
+
+type csvSource struct {
+	dailyPath string
+	intradayPath string
+	dateLayout string
+	timeLayout string
+	delimiter rune
+	location *time.Location
+	exchangeLocation *time.Location
+	columns ColumnMapping
+	index *sourceIndex
+}
+
+type barchartSource struct {
+	csvSource
+}
+
+// Optional interface for data sources that can skip the rows of CSV files that are already part of the archive being updated
+type indexedSource interface {
+	withIndex(index *sourceIndex) DataSource
+}
+
+func (a *Asset) getDataSource() DataSource {
+	if a.isSynthetic() {
+		return newSyntheticSource(a)
+	}
+	if a.Source == nil || a.Source.Type == sourceBarchart {
+		return newBarchartSource(a)
+	}
+	switch a.Source.Type {
+	case sourceCsv:
+		return newCsvSource(a, *a.Source)
+	default:
+		log.Fatalf("[%s] Unknown data source type \"%s\"", a.Symbol, a.Source.Type)
+	}
+	return nil
+}
+
+func newBarchartSource(asset *Asset) barchartSource {
+	symbol := asset.getBarchartSymbol()
+	timezone := getBarchartTimezone()
+	if asset.Source != nil && asset.Source.Timezone != "" {
+		timezone = asset.Source.Timezone
+	}
+	location := getLocation(timezone)
+	return barchartSource{
+		csvSource: csvSource{
+			dailyPath: getBarchartCsvPath(symbol, "D1"),
+			intradayPath: getBarchartCsvPath(symbol, "H1"),
+			dateLayout: dateLayout,
+			timeLayout: timestampLayout,
+			delimiter: ',',
+			location: location,
+			exchangeLocation: asset.getLocation(),
+			columns: ColumnMapping{
+				Symbol: "symbol",
+				Date: "time",
+				Time: "time",
+				Open: "open",
+				High: "high",
+				Low: "low",
+				Close: "close",
+				Volume: "volume",
+				OpenInterest: "open_interest",
+			},
+		},
+	}
+}
+
+func newCsvSource(asset *Asset, config SourceConfiguration) csvSource {
+	if config.Path == "" || config.DailyFile == "" || config.IntradayFile == "" {
+		log.Fatalf("[%s] CSV data sources require path, dailyFile and intradayFile", asset.Symbol)
+	}
+	symbol := asset.Symbol
+	if config.Symbol != "" {
+		symbol = config.Symbol
+	}
+	getPath := func (pattern string) string {
+		fileName := strings.ReplaceAll(pattern, sourceSymbolPlaceholder, symbol)
+		return filepath.Join(config.Path, fileName)
+	}
+	source := csvSource{
+		dailyPath: getPath(config.DailyFile),
+		intradayPath: getPath(config.IntradayFile),
+		dateLayout: dateLayout,
+		timeLayout: timestampLayout,
+		delimiter: ',',
+		location: asset.getLocation(),
+		exchangeLocation: asset.getLocation(),
+		columns: ColumnMapping{
+			Symbol: "symbol",
+			Date: "date",
+			Time: "time",
+			High: "high",
+			Low: "low",
+			Close: "close",
+			OpenInterest: "open_interest",
+		},
+	}
+	if config.Timezone != "" {
+		source.location = getLocation(config.Timezone)
+	}
+	if config.DateLayout != "" {
+		source.dateLayout = config.DateLayout
+	}
+	if config.TimeLayout != "" {
+		source.timeLayout = config.TimeLayout
+	}
+	if config.Delimiter != "" {
+		delimiter := []rune(config.Delimiter)
+		if len(delimiter) != 1 {
+			log.Fatalf("[%s] Invalid CSV delimiter \"%s\"", asset.Symbol, config.Delimiter)
+		}
+		source.delimiter = delimiter[0]
+	}
+	source.columns.override(config.Columns)
+	return source
+}
+
+func (s csvSource) readDailyRecords(callback func (time.Time, dailyRecord)) {
+	columns := []string{
+		s.columns.Symbol,
+		s.columns.Date,
+		s.columns.Close,
+		s.columns.OpenInterest,
+	}
+	s.index.readCsv(s.dailyPath, s.delimiter, columns, func (values []string) time.Time {
+		symbol := parseSourceGlobex(values[0])
+		date := parseSourceTime(s.dateLayout, values[1])
+		close := parseFloat(values[2])
+		openInterestString := values[3]
+		openInterest, err := strconv.Atoi(openInterestString)
+		if err != nil {
+			log.Fatalf("Failed to parse open interest value \"%s\" in CSV file (%s): %v", openInterestString, s.dailyPath, err)
+		}
+		record := dailyRecord{
+			symbol: symbol,
+			close: close,
+			openInterest: openInterest,
+		}
+		date = getDateFromTime(date)
+		callback(date, record)
+		return date
+	})
+}
+
+func (s csvSource) readIntradayRecords(callback func (globexTimeKey, intradayRecord)) {
+	columns := []string{
+		s.columns.Symbol,
+		s.columns.Time,
+		s.columns.High,
+		s.columns.Low,
+		s.columns.Close,
+	}
+	// Open and volume are optional for generic CSV sources
+	hasVolume := s.columns.Volume != ""
+	if hasVolume {
+		columns = append(columns, s.columns.Volume)
+	}
+	hasOpen := s.columns.Open != ""
+	if hasOpen {
+		columns = append(columns, s.columns.Open)
+	}
+	s.index.readCsv(s.intradayPath, s.delimiter, columns, func (values []string) time.Time {
+		symbol := parseSourceGlobex(values[0])
+		timestamp := parseSourceTime(s.timeLayout, values[1])
+		timestamp = convertTimezone(timestamp, s.location, s.exchangeLocation)
+		record := intradayRecord{
+			high: parseFloat(values[2]),
+			low: parseFloat(values[3]),
+			close: parseFloat(values[4]),
+		}
+		if hasVolume {
+			record.volume = parseFloat(values[5])
+		}
+		if hasOpen {
+			record.open = parseFloat(values[len(columns) - 1])
+		} else {
+			record.open = record.close
+		}
+		key := getGlobexTimeKey(symbol, timestamp)
+		callback(key, record)
+		return timestamp
+	})
+}
+
+func (s csvSource) withIndex(index *sourceIndex) DataSource {
+	s.index = index
+	return s
+}
+
+func (s barchartSource) withIndex(index *sourceIndex) DataSource {
+	s.index = index
+	return s
+}
+
+func (s csvSource) getPaths() []string {
+	return []string{
+		s.dailyPath,
+		s.intradayPath,
+	}
+}
+
+func (c *ColumnMapping) override(other ColumnMapping) {
+	overrideString := func (destination *string, source string) {
+		if source != "" {
+			*destination = source
+		}
+	}
+	overrideString(&c.Symbol, other.Symbol)
+	overrideString(&c.Date, other.Date)
+	overrideString(&c.Time, other.Time)
+	overrideString(&c.Open, other.Open)
+	overrideString(&c.High, other.High)
+	overrideString(&c.Low, other.Low)
+	overrideString(&c.Close, other.Close)
+	overrideString(&c.Volume, other.Volume)
+	overrideString(&c.OpenInterest, other.OpenInterest)
+}
+
+func parseSourceGlobex(symbolString string) GlobexCode {
+	symbol, err := parseGlobex(symbolString)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return symbol
+}
+
+func parseSourceTime(layout, timeString string) time.Time {
+	timestamp, err := time.Parse(layout, timeString)
+	if err != nil {
+		log.Fatalf("Failed to parse time string \"%s\" using layout \"%s\": %v", timeString, layout, err)
+	}
+	return timestamp
+}
+
+func getBarchartCsvPath(symbol string, suffix string) string {
+	filename := fmt.Sprintf("%s.%s.csv", symbol, suffix)
+	path := filepath.Join(configuration.BarchartPath, filename)
+	return path
+}
\ No newline at end of file
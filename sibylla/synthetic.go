@@ -0,0 +1,394 @@
+package sibylla
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const syntheticSpread = "spread"
+const syntheticRatio = "ratio"
+const syntheticSpreadTickSize = 0.01
+const syntheticMonthCodes = "FGHJKMNQUVXZ"
+
+type SyntheticLeg struct {
+	Symbol string `yaml:"symbol"`
+	Weight float64 `yaml:"weight"`
+}
+
+type syntheticLeg struct {
+	asset *Asset
+	weight float64
+	source DataSource
+	location *time.Location
+	daily readDailyRecordsResult
+	intradayRecords intradayRecordsMap
+	timestamps map[GlobexCode][]time.Time
+}
+
+// Combines the front contracts of the legs, each distinct combination of contracts is treated as a contract of its own.
+// The combinations are assigned consecutive Globex codes with the symbol of the synthetic asset as their root.
+type syntheticSource struct {
+	asset *Asset
+	location *time.Location
+	legs []syntheticLeg
+	loaded bool
+	combinations [][]GlobexCode
+	selections map[time.Time]GlobexCode
+	skippedSpread bool
+}
+
+// Optional interface for data sources that determine the order of contracts themselves
+type contractRanker interface {
+	rankContracts(date time.Time, records []dailyRecord) []dailyRecord
+}
+
+func (a *Asset) isSynthetic() bool {
+	return len(a.Legs) > 0
+}
+
+func (a *Asset) getSyntheticType() string {
+	if a.Synthetic == "" {
+		return syntheticSpread
+	}
+	return a.Synthetic
+}
+
+// Properties of synthetic assets that are not specified explicitly are derived from their legs
+func resolveSyntheticAssets() {
+	for i := range *assets {
+		asset := &(*assets)[i]
+		if !asset.isSynthetic() {
+			if asset.Synthetic != "" {
+				log.Fatalf("[%s] Synthetic assets require legs", asset.Symbol)
+			}
+			continue
+		}
+		syntheticType := asset.getSyntheticType()
+		if syntheticType != syntheticSpread && syntheticType != syntheticRatio {
+			log.Fatalf("[%s] Invalid synthetic asset type \"%s\"", asset.Symbol, asset.Synthetic)
+		}
+		legs := asset.getLegAssets()
+		first := legs[0]
+		if asset.Timezone == "" {
+			asset.Timezone = first.Timezone
+		}
+		if asset.Calendar == "" {
+			asset.Calendar = first.Calendar
+		}
+		if asset.Currency == "" {
+			asset.Currency = first.Currency
+		}
+		if syntheticType == syntheticSpread {
+			// Spreads are quoted as the value of the legs in the currency of the synthetic asset
+			if asset.TickSize == 0 {
+				asset.TickSize = syntheticSpreadTickSize
+			}
+			asset.TickValue = asset.TickSize
+		} else if asset.TickSize <= 0 || asset.TickValue <= 0 {
+			log.Fatalf("[%s] Ratios require a tickSize and a tickValue", asset.Symbol)
+		}
+		spreadValue := 0.0
+		brokerFee := 0.0
+		exchangeFee := 0.0
+		margin := 0.0
+		for j, leg := range legs {
+			weight := math.Abs(asset.Legs[j].Weight)
+			spreadValue += weight * float64(leg.Spread) * leg.TickValue
+			brokerFee += weight * leg.BrokerFee
+			exchangeFee += weight * leg.ExchangeFee
+			margin += weight * leg.Margin
+		}
+		if asset.BrokerFee == 0 {
+			asset.BrokerFee = brokerFee
+		}
+		if asset.ExchangeFee == 0 {
+			asset.ExchangeFee = exchangeFee
+		}
+		if asset.Margin == 0 {
+			asset.Margin = margin
+		}
+		// The bid/ask spreads of the legs are charged as the equivalent number of ticks of the synthetic asset
+		if asset.Spread == 0 {
+			asset.Spread = int(math.Ceil(spreadValue / asset.TickValue))
+		}
+	}
+}
+
+func (a *Asset) getLegAssets() []*Asset {
+	legs := []*Asset{}
+	for _, leg := range a.Legs {
+		if leg.Weight == 0 {
+			log.Fatalf("[%s] Invalid weight for leg %s", a.Symbol, leg.Symbol)
+		}
+		index := slices.IndexFunc(*assets, func (x Asset) bool {
+			return x.Symbol == leg.Symbol
+		})
+		if index < 0 {
+			log.Fatalf("[%s] Unable to find leg %s", a.Symbol, leg.Symbol)
+		}
+		legAsset := &(*assets)[index]
+		if legAsset.isSynthetic() {
+			log.Fatalf("[%s] Leg %s is a synthetic asset itself", a.Symbol, leg.Symbol)
+		}
+		legs = append(legs, legAsset)
+	}
+	return legs
+}
+
+func hasMixedCurrencySynthetics() bool {
+	return slices.ContainsFunc(*assets, func (a Asset) bool {
+		return a.isSynthetic() && a.getSyntheticType() == syntheticSpread && slices.ContainsFunc(a.getLegAssets(), func (leg *Asset) bool {
+			return leg.Currency != a.Currency
+		})
+	})
+}
+
+func (a *Asset) getLegsString() string {
+	if !a.isSynthetic() {
+		return ""
+	}
+	legStrings := []string{}
+	for _, leg := range a.Legs {
+		legStrings = append(legStrings, fmt.Sprintf("%g %s", leg.Weight, leg.Symbol))
+	}
+	return fmt.Sprintf("%s %s", a.getSyntheticType(), strings.Join(legStrings, ", "))
+}
+
+func newSyntheticSource(asset *Asset) *syntheticSource {
+	legs := []syntheticLeg{}
+	for i, legAsset := range asset.getLegAssets() {
+		leg := syntheticLeg{
+			asset: legAsset,
+			weight: asset.Legs[i].Weight,
+			source: legAsset.getDataSource(),
+			location: legAsset.getLocation(),
+		}
+		legs = append(legs, leg)
+	}
+	return &syntheticSource{
+		asset: asset,
+		location: asset.getLocation(),
+		legs: legs,
+	}
+}
+
+func (s *syntheticSource) load() {
+	if s.loaded {
+		return
+	}
+	for i := range s.legs {
+		leg := &s.legs[i]
+		leg.daily = readDailyRecords(*leg.asset, leg.source, time.Time{})
+		leg.intradayRecords = readIntradayRecords(*leg.asset, leg.source, time.Time{})
+		leg.timestamps = map[GlobexCode][]time.Time{}
+		for key := range leg.intradayRecords {
+			leg.timestamps[key.symbol] = append(leg.timestamps[key.symbol], key.timestamp)
+		}
+		for _, timestamps := range leg.timestamps {
+			sort.Slice(timestamps, func (i, j int) bool {
+				return timestamps[i].Before(timestamps[j])
+			})
+		}
+	}
+	fronts := []map[time.Time]GlobexCode{}
+	for _, leg := range s.legs {
+		legFronts := map[time.Time]GlobexCode{}
+		for _, datedRecords := range leg.daily.openIntRecords {
+			legFronts[datedRecords.date] = datedRecords.records[0].symbol
+		}
+		fronts = append(fronts, legFronts)
+	}
+	s.selections = map[time.Time]GlobexCode{}
+	for _, datedRecords := range s.legs[0].daily.openIntRecords {
+		date := datedRecords.date
+		combination := []GlobexCode{}
+		for _, legFronts := range fronts {
+			front, exists := legFronts[date]
+			if !exists {
+				break
+			}
+			combination = append(combination, front)
+		}
+		if len(combination) < len(s.legs) {
+			continue
+		}
+		index := slices.IndexFunc(s.combinations, func (c []GlobexCode) bool {
+			return slices.Equal(c, combination)
+		})
+		if index < 0 {
+			index = len(s.combinations)
+			s.combinations = append(s.combinations, combination)
+		}
+		s.selections[date] = s.getSymbol(index)
+	}
+	s.loaded = true
+}
+
+func (s *syntheticSource) getSymbol(index int) GlobexCode {
+	return GlobexCode{
+		Root: s.asset.Symbol,
+		Month: string(syntheticMonthCodes[index % len(syntheticMonthCodes)]),
+		Year: 2000 + index / len(syntheticMonthCodes),
+	}
+}
+
+func (s *syntheticSource) readDailyRecords(callback func (time.Time, dailyRecord)) {
+	s.load()
+	for index, combination := range s.combinations {
+		symbol := s.getSymbol(index)
+		for _, datedRecords := range s.legs[0].daily.openIntRecords {
+			date := datedRecords.date
+			prices := []float64{}
+			openInterest := math.MaxInt
+			for i, leg := range s.legs {
+				key := getGlobexDateKey(combination[i], date)
+				close, exists := leg.daily.dailyCloses[key]
+				if !exists {
+					break
+				}
+				prices = append(prices, close)
+				openInterest = min(openInterest, leg.daily.dailyOpenInterest[key])
+			}
+			if len(prices) < len(s.legs) {
+				continue
+			}
+			close, valid := s.combine(date, prices)
+			if !valid {
+				continue
+			}
+			record := dailyRecord{
+				symbol: symbol,
+				close: close,
+				openInterest: openInterest,
+			}
+			callback(date, record)
+		}
+	}
+}
+
+func (s *syntheticSource) readIntradayRecords(callback func (globexTimeKey, intradayRecord)) {
+	s.load()
+	for index, combination := range s.combinations {
+		symbol := s.getSymbol(index)
+		first := s.legs[0]
+		for _, firstTimestamp := range first.timestamps[combination[0]] {
+			timestamp := convertTimezone(firstTimestamp, first.location, s.location)
+			legRecords := []intradayRecord{}
+			for i, leg := range s.legs {
+				legTimestamp := convertTimezone(timestamp, s.location, leg.location)
+				record, exists := leg.intradayRecords[getGlobexTimeKey(combination[i], legTimestamp)]
+				if !exists {
+					break
+				}
+				legRecords = append(legRecords, record)
+			}
+			if len(legRecords) < len(s.legs) {
+				continue
+			}
+			record, valid := s.combineRecords(getUTCTime(timestamp, s.location), legRecords)
+			if !valid {
+				continue
+			}
+			callback(getGlobexTimeKey(symbol, timestamp), record)
+		}
+	}
+}
+
+func (s *syntheticSource) getPaths() []string {
+	paths := []string{}
+	for _, leg := range s.legs {
+		paths = append(paths, leg.source.getPaths()...)
+	}
+	return paths
+}
+
+// The selected combination comes first, followed by the ones selected later on, combinations that were rolled out of are dropped
+func (s *syntheticSource) rankContracts(date time.Time, records []dailyRecord) []dailyRecord {
+	selected, exists := s.selections[date]
+	if !exists {
+		return nil
+	}
+	activeRecords := []dailyRecord{}
+	for _, record := range records {
+		if !record.symbol.Less(selected) {
+			activeRecords = append(activeRecords, record)
+		}
+	}
+	sort.Slice(activeRecords, func (i, j int) bool {
+		return activeRecords[i].symbol.Less(activeRecords[j].symbol)
+	})
+	return activeRecords
+}
+
+// Highs and lows of the legs are combined into the most extreme values the synthetic asset could have reached
+func (s *syntheticSource) combineRecords(timestamp time.Time, records []intradayRecord) (intradayRecord, bool) {
+	getPrices := func (get func (intradayRecord, float64) float64) []float64 {
+		prices := []float64{}
+		for i, record := range records {
+			prices = append(prices, get(record, s.legs[i].weight))
+		}
+		return prices
+	}
+	open, openValid := s.combine(timestamp, getPrices(func (r intradayRecord, weight float64) float64 {
+		return r.open
+	}))
+	high, highValid := s.combine(timestamp, getPrices(func (r intradayRecord, weight float64) float64 {
+		if weight > 0 {
+			return r.high
+		}
+		return r.low
+	}))
+	low, lowValid := s.combine(timestamp, getPrices(func (r intradayRecord, weight float64) float64 {
+		if weight > 0 {
+			return r.low
+		}
+		return r.high
+	}))
+	close, closeValid := s.combine(timestamp, getPrices(func (r intradayRecord, weight float64) float64 {
+		return r.close
+	}))
+	volume := math.Inf(1)
+	for _, record := range records {
+		volume = math.Min(volume, record.volume)
+	}
+	record := intradayRecord{
+		open: open,
+		high: high,
+		low: low,
+		close: close,
+		volume: volume,
+	}
+	return record, openValid && highValid && lowValid && closeValid
+}
+
+func (s *syntheticSource) combine(timestamp time.Time, prices []float64) (float64, bool) {
+	if s.asset.getSyntheticType() == syntheticRatio {
+		ratio := 1.0
+		for i, leg := range s.legs {
+			if prices[i] <= 0 {
+				return 0.0, false
+			}
+			ratio *= math.Pow(prices[i], leg.weight)
+		}
+		return ratio, true
+	}
+	value := 0.0
+	for i, leg := range s.legs {
+		legValue := leg.weight * prices[i] / leg.asset.TickSize * leg.asset.TickValue
+		value += convertCurrencies(timestamp, legValue, leg.asset.Currency, s.asset.Currency)
+	}
+	if value <= 0 {
+		// Momentum and most other features are rates of change, which are undefined for prices that cross zero
+		if !s.skippedSpread {
+			fmt.Printf("[%s] Skipping bars with spread values that are not positive, starting with %.2f at %s\n", s.asset.Symbol, value, getTimeString(timestamp))
+			s.skippedSpread = true
+		}
+		return 0.0, false
+	}
+	return value, true
+}
\ No newline at end of file
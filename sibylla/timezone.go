@@ -0,0 +1,60 @@
+package sibylla
+
+import (
+	"log"
+	"time"
+	_ "time/tzdata"
+)
+
+const timezoneExchange = "exchange"
+
+// Barchart exports all timestamps in US Central Time, regardless of the exchange
+const defaultBarchartTimezone = "America/Chicago"
+
+func getLocation(name string) *time.Location {
+	if name == "" {
+		return time.UTC
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		log.Fatalf("Failed to load timezone \"%s\": %v", name, err)
+	}
+	return location
+}
+
+func getBarchartTimezone() string {
+	if configuration.BarchartTimezone != "" {
+		return configuration.BarchartTimezone
+	}
+	return defaultBarchartTimezone
+}
+
+func (a *Asset) getLocation() *time.Location {
+	return getLocation(a.Timezone)
+}
+
+func getReferenceLocation(reference string, exchange string) *time.Location {
+	if reference == "" || reference == timezoneExchange {
+		return getLocation(exchange)
+	}
+	return getLocation(reference)
+}
+
+// Converts an instant to a wall clock time in the specified location, stored as UTC so that it can be used as a map key
+func getLocalTime(timestamp time.Time, location *time.Location) time.Time {
+	local := timestamp.In(location)
+	return time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), 0, time.UTC)
+}
+
+func getUTCTime(localTime time.Time, location *time.Location) time.Time {
+	timestamp := time.Date(localTime.Year(), localTime.Month(), localTime.Day(), localTime.Hour(), localTime.Minute(), localTime.Second(), 0, location)
+	return timestamp.UTC()
+}
+
+func convertTimezone(localTime time.Time, source *time.Location, destination *time.Location) time.Time {
+	if source == destination {
+		return localTime
+	}
+	timestamp := getUTCTime(localTime, source)
+	return getLocalTime(timestamp, destination)
+}
\ No newline at end of file
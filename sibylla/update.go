@@ -0,0 +1,259 @@
+package sibylla
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Number of CSV rows between two checkpoints stored in the archive header
+const sourceCheckpointRows = 10000
+
+type archiveUpdate struct {
+	previous Archive
+	adjustment string
+	keepRecords int
+	base int
+	contextStart time.Time
+	since time.Time
+}
+
+type SourceCheckpoint struct {
+	Offset int64
+	// Latest timestamp of all rows in front of the offset
+	Latest time.Time
+}
+
+// Tracks checkpoints while reading CSV files so that updates can skip the rows in front of the last checkpoint older than since
+type sourceIndex struct {
+	since time.Time
+	previous []SourceChecksum
+	checkpoints map[string][]SourceCheckpoint
+	mutex sync.Mutex
+}
+
+func getArchiveUpdates(asset Asset, fLimit int) ([]*archiveUpdate, time.Time, []SourceChecksum) {
+	if hasAnchoredFeatures() {
+		fmt.Printf("[%s] Anchored features cannot be updated incrementally, regenerating archives\n", asset.Symbol)
+		return nil, time.Time{}, nil
+	}
+	adjustment := asset.getAdjustment()
+	if adjustment == adjustmentDifference {
+		fmt.Printf("[%s] Difference adjusted archives cannot be updated incrementally, regenerating archives\n", asset.Symbol)
+		return nil, time.Time{}, nil
+	}
+	updates := []*archiveUpdate{}
+	var since time.Time
+	var sources []SourceChecksum
+	for fNumber := 1; fNumber <= fLimit; fNumber++ {
+		path := getArchivePath(asset.Symbol, fNumber)
+		_, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			fmt.Printf("[%s] Archive does not exist yet, regenerating archives: %s\n", asset.Symbol, path)
+			return nil, time.Time{}, nil
+		}
+		header := readArchiveHeader(path)
+		if header.Version != archiveVersion || len(header.getMismatches(&asset)) > 0 {
+			fmt.Printf("[%s] Archive was generated with a different format or configuration, regenerating archives: %s\n", asset.Symbol, path)
+			return nil, time.Time{}, nil
+		}
+		previous := readArchive(path)
+		if !slices.Equal(previous.Features, getFeatureNames()) {
+			fmt.Printf("[%s] Feature catalog changed, regenerating archives: %s\n", asset.Symbol, path)
+			return nil, time.Time{}, nil
+		}
+		if !slices.Equal(previous.HoldingTimes, getHoldingTimes()) {
+			fmt.Printf("[%s] Holding times changed, regenerating archives: %s\n", asset.Symbol, path)
+			return nil, time.Time{}, nil
+		}
+		if previous.Timezone != asset.Timezone {
+			fmt.Printf("[%s] Timezone of archive changed, regenerating archives: %s\n", asset.Symbol, path)
+			return nil, time.Time{}, nil
+		}
+		update := newArchiveUpdate(previous, adjustment, asset.getCalendar())
+		if update == nil {
+			fmt.Printf("[%s] Archive is empty, regenerating archives: %s\n", asset.Symbol, path)
+			return nil, time.Time{}, nil
+		}
+		if fNumber == 1 || update.since.Before(since) {
+			since = update.since
+		}
+		if fNumber == 1 {
+			sources = header.Sources
+		}
+		updates = append(updates, update)
+	}
+	return updates, since, sources
+}
+
+func newArchiveUpdate(previous Archive, adjustment string, calendar *tradingCalendar) *archiveUpdate {
+	records := previous.IntradayRecords
+	if len(records) == 0 {
+		return nil
+	}
+	lastTimestamp := records[len(records) - 1].Timestamp
+	location := getLocation(previous.Timezone)
+	recomputeFrom := getAdjustedTimestamp(-getMaxReturnsDays(), 0, getLocalTime(lastTimestamp, location), calendar)
+	recomputeFrom = getUTCTime(recomputeFrom, location)
+	keepRecords := sort.Search(len(records), func (i int) bool {
+		return !records[i].Timestamp.Before(recomputeFrom)
+	})
+	base := keepRecords
+	if configuration.QuantileTransform {
+		keepRecords = min(keepRecords, max(len(records) - configuration.QuantileStride, 0))
+		base = max(keepRecords - configuration.QuantileBufferSize, 0)
+	}
+	contextStart := records[base].Timestamp
+	localContextStart := getLocalTime(contextStart, location)
+	since := getDateFromTime(getAdjustedTimestamp(-getMaxLookbackDays(), 0, localContextStart, calendar))
+	update := archiveUpdate{
+		previous: previous,
+		adjustment: adjustment,
+		keepRecords: keepRecords,
+		base: base,
+		contextStart: contextStart,
+		since: since,
+	}
+	return &update
+}
+
+func (u *archiveUpdate) filterTimestamps(timestamps []time.Time, location *time.Location) []time.Time {
+	index := sort.Search(len(timestamps), func (i int) bool {
+		closeTimestamp := getUTCTime(getCloseTimestamp(timestamps[i]), location)
+		return !closeTimestamp.Before(u.contextStart)
+	})
+	return timestamps[index:]
+}
+
+func (u *archiveUpdate) merge(archive *Archive) {
+	previousRecords := u.previous.IntradayRecords
+	generatedRecords := archive.IntradayRecords
+	contextRecords := u.keepRecords - u.base
+	if len(generatedRecords) < contextRecords {
+		log.Fatalf("[%s] Unable to regenerate the tail of the archive, regenerate it without -update", archive.Symbol)
+	}
+	for i, record := range generatedRecords[:contextRecords] {
+		previousTimestamp := previousRecords[u.base + i].Timestamp
+		if !record.Timestamp.Equal(previousTimestamp) {
+			format := "[%s] Archive does not match CSV data at %s, regenerate it without -update"
+			log.Fatalf(format, archive.Symbol, getTimeString(previousTimestamp))
+		}
+	}
+	if configuration.QuantileTransform {
+		generatedRecords = quantileTransform(configuration.QuantileBufferSize, configuration.QuantileStride, u.base, generatedRecords)
+	}
+	intradayRecords := previousRecords[:u.keepRecords:u.keepRecords]
+	intradayRecords = append(intradayRecords, generatedRecords[contextRecords:]...)
+	dailyRecords := []DailyRecord{}
+	scale := u.getDailyScale(archive.DailyRecords)
+	for _, record := range u.previous.DailyRecords {
+		if len(archive.DailyRecords) > 0 && !record.Date.Before(archive.DailyRecords[0].Date) {
+			break
+		}
+		record.Close *= scale
+		dailyRecords = append(dailyRecords, record)
+	}
+	dailyRecords = append(dailyRecords, archive.DailyRecords...)
+	rolls := []Roll{}
+	for _, roll := range u.previous.Rolls {
+		if len(archive.DailyRecords) > 0 && !roll.Date.Before(archive.DailyRecords[0].Date) {
+			break
+		}
+		rolls = append(rolls, roll)
+	}
+	rolls = append(rolls, archive.Rolls...)
+	addedRecords := len(intradayRecords) - len(previousRecords)
+	fmt.Printf("[%s] Updated %d records, added %d new records\n", archive.Symbol, len(intradayRecords) - u.keepRecords, addedRecords)
+	archive.DailyRecords = dailyRecords
+	archive.IntradayRecords = intradayRecords
+	archive.Rolls = rolls
+	archive.Bars = mergeBarSeries(u.previous.Bars, archive.Bars)
+}
+
+func (u *archiveUpdate) getDailyScale(dailyRecords []DailyRecord) float64 {
+	if u.adjustment != adjustmentRatio || len(dailyRecords) == 0 {
+		return 1.0
+	}
+	first := dailyRecords[0]
+	for _, record := range u.previous.DailyRecords {
+		if record.Date.Equal(first.Date) && record.Close > 0 {
+			return first.Close / record.Close
+		}
+	}
+	log.Fatalf("[%s] Unable to rescale ratio adjusted daily records, regenerate the archive without -update", u.previous.Symbol)
+	return 0.0
+}
+
+func newSourceIndex(since time.Time, previous []SourceChecksum) *sourceIndex {
+	return &sourceIndex{
+		since: since,
+		previous: previous,
+		checkpoints: map[string][]SourceCheckpoint{},
+	}
+}
+
+// Files are only resumed if they were appended to since the archive was generated
+func (i *sourceIndex) getPrevious(path string) (SourceChecksum, bool) {
+	previous, exists := find(i.previous, func (c SourceChecksum) bool {
+		return c.Path == path
+	})
+	if !exists || previous.Size == 0 {
+		return SourceChecksum{}, false
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Size() < previous.Size {
+		return SourceChecksum{}, false
+	}
+	return previous, true
+}
+
+// The callback returns the timestamp of the row it processed, rows before since are passed on too and need to be skipped by it
+func (i *sourceIndex) readCsv(path string, delimiter rune, columns []string, callback func ([]string) time.Time) {
+	if i == nil {
+		readCsvDelimiter(path, delimiter, columns, func (values []string) {
+			callback(values)
+		})
+		return
+	}
+	checkpoints := []SourceCheckpoint{}
+	var resume SourceCheckpoint
+	previous, exists := i.getPrevious(path)
+	if exists {
+		for _, checkpoint := range previous.Checkpoints {
+			if !checkpoint.Latest.Before(i.since) {
+				break
+			}
+			checkpoints = append(checkpoints, checkpoint)
+			resume = checkpoint
+		}
+	}
+	latest := resume.Latest
+	rows := 0
+	readCsvOffset(path, delimiter, resume.Offset, columns, func (values []string, offset int64) {
+		if rows > 0 && rows % sourceCheckpointRows == 0 {
+			checkpoint := SourceCheckpoint{
+				Offset: offset,
+				Latest: latest,
+			}
+			checkpoints = append(checkpoints, checkpoint)
+		}
+		rows++
+		timestamp := callback(values)
+		if timestamp.After(latest) {
+			latest = timestamp
+		}
+	})
+	i.mutex.Lock()
+	i.checkpoints[path] = checkpoints
+	i.mutex.Unlock()
+}
+
+func (i *sourceIndex) getCheckpoints(path string) []SourceCheckpoint {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return i.checkpoints[path]
+}
\ No newline at end of file
@@ -0,0 +1,88 @@
+package sibylla
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeSourceTestFile(t *testing.T, path string, start time.Time, rows int, appendRows bool) {
+	builder := strings.Builder{}
+	if !appendRows {
+		builder.WriteString("time,close\n")
+	}
+	for i := range rows {
+		timestamp := start.Add(time.Duration(i) * time.Hour)
+		fmt.Fprintf(&builder, "%s,%d\n", timestamp.Format(timestampLayout), i)
+	}
+	flags := os.O_CREATE | os.O_WRONLY
+	if appendRows {
+		flags |= os.O_APPEND
+	}
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	_, err = file.WriteString(builder.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSourceIndexResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ES.H1.csv")
+	source := csvSource{
+		dailyPath: path,
+		intradayPath: path,
+	}
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	initialRows := 3 * sourceCheckpointRows + 123
+	writeSourceTestFile(t, path, start, initialRows, false)
+	readTimestamps := func (index *sourceIndex) []time.Time {
+		timestamps := []time.Time{}
+		index.readCsv(path, ',', []string{"time"}, func (values []string) time.Time {
+			timestamp := getTime(values[0])
+			timestamps = append(timestamps, timestamp)
+			return timestamp
+		})
+		return timestamps
+	}
+	index := newSourceIndex(time.Time{}, nil)
+	timestamps := readTimestamps(index)
+	if len(timestamps) != initialRows {
+		t.Fatalf("Read %d rows, expected %d", len(timestamps), initialRows)
+	}
+	previous := getSourceChecksums(source, index)
+	addedRows := 50
+	writeSourceTestFile(t, path, start.Add(time.Duration(initialRows) * time.Hour), addedRows, true)
+	since := start.Add(time.Duration(initialRows - 500) * time.Hour)
+	index = newSourceIndex(since, previous[:1])
+	timestamps = readTimestamps(index)
+	totalRows := initialRows + addedRows
+	// The last checkpoint is preceded by rows after since, so reading resumes at the second one
+	expectedRows := totalRows - 2 * sourceCheckpointRows
+	if len(timestamps) != expectedRows {
+		t.Fatalf("Read %d rows after resuming, expected %d", len(timestamps), expectedRows)
+	}
+	if timestamps[len(timestamps) - 1] != start.Add(time.Duration(totalRows - 1) * time.Hour) {
+		t.Fatalf("Last row was not read")
+	}
+	checksums := getSourceChecksums(source, index)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256(data)
+	if checksums[0].SHA256 != hex.EncodeToString(hash[:]) || checksums[0].Size != int64(len(data)) {
+		t.Errorf("Incremental checksum does not match the checksum of the complete file")
+	}
+	if len(checksums[0].Checkpoints) != 3 {
+		t.Errorf("Found %d checkpoints, expected 3", len(checksums[0].Checkpoints))
+	}
+}
\ No newline at end of file
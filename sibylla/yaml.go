@@ -17,97 +17,99 @@ func GenerateStrategyYaml(inputPath, outputPath string) {
 	content := string(bytes)
 	content = strings.ReplaceAll(content, "\r", "")
 	lines := strings.Split(content, "\n")
-	output := "strategies:\n"
-	
+	strategies := ""
+	timezone := ""
 	for _, line := range lines {
 		if line == "" {
 			continue
 		}
-		if generateTwoFeatureStrategy(line, &output) {
-			continue
-		}
-		if generateSingleFeatureStrategy(line, &output) {
+		if generateStrategy(line, &strategies, &timezone) {
 			continue
 		}
 		log.Fatalf("Unable to parse line: %s", line)
 	}
+	output := ""
+	if timezone != "" {
+		output += fmt.Sprintf("timezone: %s\n", timezone)
+	}
+	output += "strategies:\n" + strategies
 	err = os.WriteFile(outputPath, []byte(output), 0644)
 	if err != nil {
 		log.Fatal("Failed to write file")
 	}
 }
 
-func generateTwoFeatureStrategy(line string, output *string) bool {
-	pattern := regexp.MustCompile(`^(.+?)\.(momentum.+?) \((\d+(?:\.\d+)?), (\d+(?:\.\d+)?)\), (.+?)\.(momentum.+?) \((\d+(?:\.\d+)?), (\d+(?:\.\d+)?)\), (long|short), (\d+:\d+), (\d+)h(?:, SL (\d+\.\d+)%)?$`)
-	matches := pattern.FindStringSubmatch(line)
+var strategyPattern = regexp.MustCompile(`^(.+?), (long|short), (\d+:\d+)(?: \(([^)]+)\))?, (\d+)h(?:, SL (\d+\.\d+)%)?(?:, TP (\d+\.\d+)%)?(?:, TS (\d+\.\d+)%)?$`)
+var conditionPattern = regexp.MustCompile(`^([^ ,.]+)\.([A-Za-z][A-Za-z0-9]*) \((-?\d+(?:\.\d+)?), (-?\d+(?:\.\d+)?)\)(?:, |$)`)
+
+// Parses lines with any number of conditions, the first condition determines the symbol traded.
+// The time of day may be followed by the timezone it was data mined in, which applies to all strategies of the file.
+func generateStrategy(line string, output *string, timezone *string) bool {
+	matches := strategyPattern.FindStringSubmatch(line)
 	if matches == nil {
 		return false
 	}
-	symbol1 := matches[1]
-	feature1 := matches[2]
-	min1 := matches[3]
-	max1 := matches[4]
-	symbol2 := matches[5]
-	feature2 := matches[6]
-	min2 := matches[7]
-	max2 := matches[8]
-	side := matches[9]
-	time := matches[10]
-	holdingTime := matches[11]
-	stopLoss := getStopLossFromString(matches[12])
-	*output += fmt.Sprintf("  - symbol: %s\n", symbol1)
-	*output += fmt.Sprintf("    side: %s\n", side)
-	*output += fmt.Sprintf("    time: %s\n", time)
-	*output += fmt.Sprintf("    holdingTime: %s\n", holdingTime)
-	if stopLoss != nil {
-		*output += fmt.Sprintf("    stopLoss: %.3f\n", *stopLoss)
+	conditionsString := matches[1]
+	side := matches[2]
+	time := matches[3]
+	strategyTimezone := matches[4]
+	holdingTime := matches[5]
+	stopLoss := getExitFromString(matches[6], "SL")
+	takeProfit := getExitFromString(matches[7], "TP")
+	trailingStop := getExitFromString(matches[8], "TS")
+	conditions := [][]string{}
+	for conditionsString != "" {
+		conditionMatches := conditionPattern.FindStringSubmatch(conditionsString)
+		if conditionMatches == nil {
+			return false
+		}
+		conditions = append(conditions, conditionMatches[1:])
+		conditionsString = conditionsString[len(conditionMatches[0]):]
 	}
-	*output += "    conditions:\n"
-	*output += fmt.Sprintf("      - feature: %s\n", feature1)
-	*output += fmt.Sprintf("        min: %s\n", min1)
-	*output += fmt.Sprintf("        max: %s\n", max1)
-	*output += fmt.Sprintf("      - symbol: %s\n", symbol2)
-	*output += fmt.Sprintf("        feature: %s\n", feature2)
-	*output += fmt.Sprintf("        min: %s\n", min2)
-	*output += fmt.Sprintf("        max: %s\n", max2)
-	return true
-}
-
-func generateSingleFeatureStrategy(line string, output *string) bool {
-	pattern := regexp.MustCompile(`^(.+?)\.(momentum.+?) \((\d+(?:\.\d+)?), (\d+(?:\.\d+)?)\), (long|short), (\d+:\d+), (\d+)h(?:, SL (\d+\.\d+)%)?$`)
-	matches := pattern.FindStringSubmatch(line)
-	if matches == nil {
-		return false
+	if strategyTimezone != "" {
+		if *timezone != "" && *timezone != strategyTimezone {
+			log.Fatalf("Strategies mined in different timezones can't be backtested together: %s vs. %s", *timezone, strategyTimezone)
+		}
+		*timezone = strategyTimezone
 	}
-	symbol := matches[1]
-	feature := matches[2]
-	min := matches[3]
-	max := matches[4]
-	side := matches[5]
-	time := matches[6]
-	holdingTime := matches[7]
-	stopLoss := getStopLossFromString(matches[8])
-	*output += fmt.Sprintf("  - symbol: %s\n", symbol)
+	*output += fmt.Sprintf("  - symbol: %s\n", conditions[0][0])
 	*output += fmt.Sprintf("    side: %s\n", side)
 	*output += fmt.Sprintf("    time: %s\n", time)
 	*output += fmt.Sprintf("    holdingTime: %s\n", holdingTime)
 	if stopLoss != nil {
 		*output += fmt.Sprintf("    stopLoss: %.3f\n", *stopLoss)
 	}
+	if takeProfit != nil {
+		*output += fmt.Sprintf("    takeProfit: %.3f\n", *takeProfit)
+	}
+	if trailingStop != nil {
+		*output += fmt.Sprintf("    trailingStop: %.3f\n", *trailingStop)
+	}
 	*output += "    conditions:\n"
-	*output += fmt.Sprintf("      - feature: %s\n", feature)
-	*output += fmt.Sprintf("        min: %s\n", min)
-	*output += fmt.Sprintf("        max: %s\n", max)
+	for i, condition := range conditions {
+		symbol := condition[0]
+		feature := condition[1]
+		min := condition[2]
+		max := condition[3]
+		if i == 0 {
+			*output += fmt.Sprintf("      - feature: %s\n", feature)
+		} else {
+			*output += fmt.Sprintf("      - symbol: %s\n", symbol)
+			*output += fmt.Sprintf("        feature: %s\n", feature)
+		}
+		*output += fmt.Sprintf("        min: %s\n", min)
+		*output += fmt.Sprintf("        max: %s\n", max)
+	}
 	return true
 }
 
-func getStopLossFromString(input string) *float64 {
+func getExitFromString(input string, name string) *float64 {
 	if input == "" {
 		return nil
 	}
 	value, err := strconv.ParseFloat(input, 64)
 	if err != nil {
-		log.Fatal("Failed to parse SL value")
+		log.Fatalf("Failed to parse %s value", name)
 	}
 	value /= 100.0
 	return &value
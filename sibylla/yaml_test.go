@@ -0,0 +1,94 @@
+package sibylla
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestStrategyYamlRoundTrip(t *testing.T) {
+	stopLoss := 0.01
+	takeProfit := 0.025
+	trailingStop := 0.015
+	tests := []struct {
+		name string
+		stopLoss *float64
+		takeProfit *float64
+		trailingStop *float64
+	}{
+		{"no exits", nil, nil, nil},
+		{"stop-loss", &stopLoss, nil, nil},
+		{"take-profit", nil, &takeProfit, nil},
+		{"trailing stop", nil, nil, &trailingStop},
+		{"all exits", &stopLoss, &takeProfit, &trailingStop},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func (t *testing.T) {
+			timeOfDay := 14 * time.Hour
+			backtest := backtestData{
+				symbol: "ES",
+				side: SideShort,
+				timeOfDay: &timeOfDay,
+				returns: returnsAccessor{holdingTime: 24},
+				conditions: []strategyCondition{
+					{
+						asset: assetRecords{asset: Asset{Symbol: "ES"}},
+						feature: featureAccessor{name: "momentum1D"},
+						min: 0.2,
+						max: 0.4,
+					},
+					{
+						asset: assetRecords{asset: Asset{Symbol: "NQ"}},
+						feature: featureAccessor{name: "volatility"},
+						min: -1.5,
+						max: 0.5,
+					},
+				},
+			}
+			if test.stopLoss != nil {
+				backtest.enableStopLoss = true
+				backtest.stopLoss = test.stopLoss
+			}
+			if test.takeProfit != nil || test.trailingStop != nil {
+				backtest.exits = &barExits{
+					takeProfit: test.takeProfit,
+					trailingStop: test.trailingStop,
+				}
+			}
+			line := backtest.getStrategyString()
+			strategies := ""
+			timezone := ""
+			if !generateStrategy(line, &strategies, &timezone) {
+				t.Fatalf("Unable to parse line: %s", line)
+			}
+			var backtestConfig BacktestConfiguration
+			err := yaml.Unmarshal([]byte("strategies:\n" + strategies), &backtestConfig)
+			if err != nil {
+				t.Fatalf("Failed to parse generated YAML: %v\n%s", err, strategies)
+			}
+			if len(backtestConfig.Strategies) != 1 {
+				t.Fatalf("Generated %d strategies, expected 1", len(backtestConfig.Strategies))
+			}
+			strategy := backtestConfig.Strategies[0]
+			if strategy.Symbol != "ES" || strategy.Side.PositionSide != SideShort || strategy.Time.Duration != timeOfDay || strategy.HoldingTime != 24 {
+				t.Errorf("Strategy does not match line: %s\n%s", line, strategies)
+			}
+			expectedConditions := []StrategyCondition{
+				{Feature: "momentum1D", Min: 0.2, Max: 0.4},
+				{Symbol: "NQ", Feature: "volatility", Min: -1.5, Max: 0.5},
+			}
+			if len(strategy.Conditions) != len(expectedConditions) {
+				t.Fatalf("Strategy has %d conditions, expected %d", len(strategy.Conditions), len(expectedConditions))
+			}
+			for i, condition := range strategy.Conditions {
+				if condition != expectedConditions[i] {
+					t.Errorf("Condition %d = %+v, expected %+v", i, condition, expectedConditions[i])
+				}
+			}
+			if !equalFeature(strategy.StopLoss, test.stopLoss) || !equalFeature(strategy.TakeProfit, test.takeProfit) || !equalFeature(strategy.TrailingStop, test.trailingStop) {
+				t.Errorf("Exits do not match line: %s\n%s", line, strategies)
+			}
+		})
+	}
+}
\ No newline at end of file
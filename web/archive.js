@@ -5,6 +5,7 @@ function renderArchiveUI() {
 	createElement("img", dailyRecords, {
 		src: model.plot
 	});
+	renderRolls(model, topLevel);
 	model.properties.forEach(property => {
 		const container = createElement("div", topLevel, "property");
 		const addMissingValueStyle = valueCell => {
@@ -40,6 +41,31 @@ function renderArchiveUI() {
 	});
 }
 
+function renderRolls(model, container) {
+	if (model.rolls.length === 0) {
+		return;
+	}
+	const rolls = createElement("div", container, "rolls");
+	const table = createElement("table", rolls);
+	const headerRow = createElement("tr", table);
+	const headers = [
+		"Roll Date",
+		"From",
+		"To",
+	];
+	headers.forEach(header => {
+		const cell = createElement("th", headerRow);
+		cell.textContent = header;
+	});
+	model.rolls.forEach(roll => {
+		const row = createElement("tr", table);
+		[roll.date, roll.from, roll.to].forEach(value => {
+			const cell = createElement("td", row);
+			cell.textContent = value;
+		});
+	});
+}
+
 function roundValue(value) {
 	if (Number.isInteger(value)) {
 		return value.toString();
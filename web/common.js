@@ -29,10 +29,13 @@ function getPercentage(value, precision) {
 	return `${percentage}%`;
 }
 
-function formatMoney(amount) {
+function formatMoney(amount, currency) {
+	if (currency == null) {
+		currency = "USD";
+	}
 	const options = {
 		style: "currency",
-		currency: "USD",
+		currency: currency,
 	};
 	const format = new Intl.NumberFormat("en-US", options);
 	const output = format.format(amount);
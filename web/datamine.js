@@ -50,7 +50,7 @@ function renderDataMiningUI() {
 				options.push("-");
 			}
 			const optionsString = options.join(", ");
-			const timeOfDay = strategy.timeOfDay != null ? strategy.timeOfDay : "-";
+			const timeOfDay = strategy.timeOfDay != null ? `${strategy.timeOfDay} (${model.timezone})` : "-";
 			const holdingTimePattern = /\d+/;
 			const holdingTimeMatch = holdingTimePattern.exec(strategy.exit);
 			const holdingTimeHours = parseInt(holdingTimeMatch[0]);
@@ -58,29 +58,35 @@ function renderDataMiningUI() {
 			let cells1;
 			const daysTraded = ["Days Traded", getPercentage(strategy.tradesRatio, 1), false];
 			if (model.seasonalityMode === true) {
+				const seasonality = strategy.weekday !== null ? ["Weekday", getWeekdayString(strategy.weekday), false] : ["Condition", features[0], false];
 				cells1 = [
 					["Side", side, false],
-					["Weekday", getWeekdayString(strategy.weekday), false],
+					seasonality,
 					["Entry", timeOfDay, false],
 					["Holding Time", holdingTime, false],
 					["Options", optionsString, false],
 					daysTraded,
 				];
 			} else {
-				const feature1 = features[0];
-				const feature2 = model.singleFeature === false ? features[1] : "-";
-				cells1 = [
-					["Feature 1", feature1, false],
-					["Feature 2", feature2, false],
+				let featureCells;
+				if (model.singleFeature === true) {
+					featureCells = [
+						["Feature 1", features[0], false],
+						["Feature 2", "-", false],
+					];
+				} else {
+					featureCells = features.map((feature, i) => [`Feature ${i + 1}`, feature, false]);
+				}
+				cells1 = featureCells.concat([
 					["Side", side, false],
 					["Entry", timeOfDay, false],
 					["Holding Time", holdingTime, false],
 					["Options", optionsString, false],
 					daysTraded,
-				];
+				]);
 			}
 			const cells2 = [
-				["Returns", formatMoney(strategy.returns), true],
+				["Returns", formatMoney(strategy.returns, model.currency), true],
 				getSharpeRatio("Total SR", strategy.sharpe),
 				getSharpeRatio("Min SR", strategy.minSharpe),
 				getSharpeRatio("Recent SR", strategy.recentSharpe),